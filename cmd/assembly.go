@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/mattkimber/gandalf/magica"
+	"github.com/mattkimber/gorender/internal/geometry"
+	"github.com/mattkimber/gorender/internal/manifest"
+	"github.com/mattkimber/gorender/internal/raycaster"
+	"github.com/mattkimber/gorender/internal/transform"
+	"github.com/mattkimber/gorender/internal/utils/fileutils"
+	"io"
+	"math"
+	"path/filepath"
+	"strconv"
+)
+
+// A part of an object to render on its own, with the box it was cropped from
+type assemblyPart struct {
+	name   string
+	box    geometry.Bounds
+	frames []magica.VoxelObject
+}
+
+// Output giving where each part's sprites should be drawn relative to the
+// sprites of the whole object
+type assemblyOffsets struct {
+	Parts []partOffsets `json:"parts"`
+}
+
+type partOffsets struct {
+	Name    string         `json:"name"`
+	Sprites []spriteOffset `json:"sprites"`
+}
+
+type spriteOffset struct {
+	Angle float64 `json:"angle"`
+	X     int     `json:"x"`
+	Y     int     `json:"y"`
+}
+
+func getAssemblyParts(inputFilename string, frames []magica.VoxelObject, assembly *manifest.Assembly) (parts []assemblyPart, err error) {
+	for i, p := range assembly.Parts {
+		part := assemblyPart{name: getPartName(i, p)}
+
+		source := frames
+		if p.File != "" {
+			object, err := magica.FromFile(getPartFilename(inputFilename, p))
+			if err != nil {
+				return nil, fmt.Errorf("could not load part %s: %v", part.name, err)
+			}
+
+			// Parts from other files are the same in every frame
+			source = make([]magica.VoxelObject, len(frames))
+			for f := range source {
+				source[f] = object
+			}
+		}
+
+		part.box = transform.GetBounds(source[0])
+		if p.Min != nil {
+			part.box.Min = *p.Min
+		}
+		if p.Max != nil {
+			part.box.Max = *p.Max
+		}
+		part.box = part.box.Intersect(transform.GetBounds(source[0]))
+
+		for _, f := range source {
+			part.frames = append(part.frames, transform.Crop(f, part.box))
+		}
+
+		parts = append(parts, part)
+	}
+
+	return
+}
+
+// Get the name added to the output filenames of a part
+func getPartName(i int, p manifest.AssemblyPart) string {
+	if p.Name == "" {
+		return "part" + strconv.Itoa(i)
+	}
+
+	return p.Name
+}
+
+// Get the voxel file a part is taken from, which is relative to the input file
+func getPartFilename(inputFilename string, p manifest.AssemblyPart) string {
+	return filepath.Join(filepath.Dir(inputFilename), p.File)
+}
+
+// Parts are framed in their own sprites, which are not always centred on the
+// part (e.g. with pad_to_full_length), so the offset of a part is found from
+// where the same point appears in the sprites of the part and the whole object
+func writeAssemblyOffsets(outputFilename string, scale string, m manifest.Manifest, object magica.VoxelObject, parts []assemblyPart) error {
+	scaleF, err := strconv.ParseFloat(scale, 64)
+	if err != nil {
+		return fmt.Errorf("could not interpret scale %s: %v", scale, err)
+	}
+
+	point := transform.GetBounds(object).Centre()
+	point.Z = 0
+	size := geometry.FromGandalfPoint(object.Size)
+	output := assemblyOffsets{}
+
+	for _, part := range parts {
+		po := partOffsets{Name: part.name}
+
+		// Parts are cropped in X and Y only, so keep their full height
+		partPoint := point.Subtract(geometry.Vector3{X: float64(part.box.Min.X), Y: float64(part.box.Min.Y)})
+		partSize := geometry.FromGandalfPoint(part.frames[0].Size)
+
+		for _, spr := range m.Sprites {
+			wx, wy := raycaster.GetScreenPosition(m, spr, size, point)
+			px, py := raycaster.GetScreenPosition(m, spr, partSize, partPoint)
+			po.Sprites = append(po.Sprites, spriteOffset{
+				Angle: spr.Angle,
+				X:     int(math.Round((wx - px) * scaleF)),
+				Y:     int(math.Round((wy - py) * scaleF)),
+			})
+		}
+
+		output.Parts = append(output.Parts, po)
+	}
+
+	return fileutils.WriteToFile(outputFilename+"_parts.json", output)
+}
+
+func (a assemblyOffsets) OutputToWriter(w io.Writer) error {
+	data, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(data)
+	return err
+}
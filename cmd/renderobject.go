@@ -7,6 +7,7 @@ import (
 	"github.com/mattkimber/gorender/internal/colour"
 	"github.com/mattkimber/gorender/internal/manifest"
 	"github.com/mattkimber/gorender/internal/spritesheet"
+	"github.com/mattkimber/gorender/internal/transform"
 	"github.com/mattkimber/gorender/internal/utils/fileutils"
 	"github.com/mattkimber/gorender/internal/utils/timingutils"
 	"github.com/mattkimber/gorender/internal/voxelobject"
@@ -33,6 +34,17 @@ type Flags struct {
 	StripDirectory                bool
 	ProgressIndicator             bool
 	PaletteFile                   string
+	PaletteRangesFile             string
+	VoxPalette                    bool
+	VoxPaletteRangesFile          string
+	Remap                         bool
+	RemapVox                      bool
+	CompanyColourPreview          bool
+	CompanyColourPreviewColours   string
+	AnimationPreview              bool
+	Turntable                     float64
+	TurntableScale                int
+	TurntableFrames               bool
 	Overwrite                     bool
 }
 
@@ -53,6 +65,17 @@ func init() {
 	flag.BoolVar(&flags.StripDirectory, "strip-directory", false, "strip paths from input files")
 	flag.BoolVar(&flags.ProgressIndicator, "progress", false, "show simple progress indicator")
 	flag.StringVar(&flags.PaletteFile, "palette", "files/ttd_palette.json", "specify a palette file other than the default")
+	flag.StringVar(&flags.PaletteRangesFile, "palette-ranges", "", "JSON file of palette ranges and settings for .pal, .gpl, .act or .png palettes")
+	flag.BoolVar(&flags.VoxPalette, "vox-palette", false, "render with the palette stored in the voxel file, using -palette for 8bpp output")
+	flag.StringVar(&flags.VoxPaletteRangesFile, "vox-palette-ranges", "", "JSON file of palette ranges and settings for the voxel file palette")
+	flag.BoolVar(&flags.Remap, "remap", false, "recolour voxel files to the closest colours in -palette before rendering")
+	flag.BoolVar(&flags.RemapVox, "remap-vox", false, "write voxel files recoloured to the closest colours in -palette instead of rendering")
+	flag.BoolVar(&flags.CompanyColourPreview, "cc-preview", false, "output a preview of the 32bpp sprites in each company colour")
+	flag.StringVar(&flags.CompanyColourPreviewColours, "cc-preview-colours", "", "primary and optional secondary company colour to preview, e.g. red,white")
+	flag.BoolVar(&flags.AnimationPreview, "anim-preview", false, "output an animated GIF of the palette animation for each sprite with animated colours")
+	flag.Float64Var(&flags.Turntable, "turntable", 0, "output a preview of the object turning around, with a sprite every this many degrees")
+	flag.IntVar(&flags.TurntableScale, "turntable-scale", 1, "scale the turntable preview up by this factor")
+	flag.BoolVar(&flags.TurntableFrames, "turntable-frames", false, "output the turntable preview as a PNG for each angle instead of an animated GIF")
 	flag.BoolVar(&flags.Overwrite, "overwrite", false, "force overwriting of existing files")
 
 	flag.BoolVar(&flags.Fast, "fast", false, "force fast rendering output")
@@ -100,11 +123,23 @@ func processFile(inputFilename string) {
 	splitScales := strings.Split(flags.Scales, ",")
 	numScales := len(splitScales)
 
-	allFilesExist := true
+	renderManifest, err := getManifest(flags.ManifestFilename)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Remapped voxel files are always written, as are animation previews
+	// and turntable frames because which files they produce isn't known
+	// until they are rendered
+	allFilesExist := !flags.RemapVox && !flags.AnimationPreview && !flags.TurntableFrames
 
 	// Check if there are files to output
 	for _, scale := range splitScales {
-		exist, err := allPotentialOutputFilesExist(inputFilename, scale, numScales, flags.ManifestFilename)
+		if !allFilesExist {
+			break
+		}
+
+		exist, err := allPotentialOutputFilesExist(inputFilename, scale, numScales, flags.ManifestFilename, renderManifest)
 
 		if err != nil {
 			fmt.Printf("error attempting to stat files: %v", err)
@@ -124,14 +159,15 @@ func processFile(inputFilename string) {
 		return
 	}
 
-	palette, err := getPalette(flags.PaletteFile)
+	palette, err := getPalette(flags.PaletteFile, flags.PaletteRangesFile)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	renderManifest, err := getManifest(flags.ManifestFilename)
-	if err != nil {
-		log.Fatal(err)
+	if flags.CompanyColourPreview {
+		if _, err := palette.GetCompanyColourPairs(getCompanyColourPreviewColours()); err != nil {
+			log.Fatal(err)
+		}
 	}
 
 	if flags.Fast {
@@ -145,6 +181,46 @@ func processFile(inputFilename string) {
 		log.Fatal(err)
 	}
 
+	if flags.RemapVox {
+		object, err = remapObject(inputFilename, object, palette, renderManifest.ColourDistance)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if err := object.SaveToFile(getRemappedFilename(inputFilename)); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	frames, err := getFrames(inputFilename, object, renderManifest)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if flags.Remap {
+		for i := range frames {
+			if frames[i], err = remapObject(inputFilename, frames[i], palette, renderManifest.ColourDistance); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+
+	object = frames[0]
+
+	// When using the voxel file's palette, the -palette file becomes the
+	// palette the 8bpp output is quantised to
+	var targetPalette *colour.Palette
+	if flags.VoxPalette {
+		target := palette
+		targetPalette = &target
+
+		palette, err = getVoxPalette(object, flags.VoxPaletteRangesFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	if flags.ProfileFile != "" {
 		f, err := os.Create(flags.ProfileFile)
 		if err != nil {
@@ -159,16 +235,41 @@ func processFile(inputFilename string) {
 		defer pprof.StopCPUProfile()
 	}
 
-	var processedObject voxelobject.ProcessedVoxelObject
-	timingutils.Time("Voxel processing", flags.OutputTime, func() {
-		processedObject = voxelobject.GetProcessedVoxelObject(object, &palette, renderManifest.TiledNormals, renderManifest.TilingMode, renderManifest.SolidBase)
-	})
+	parts := []assemblyPart{{frames: frames}}
+	if renderManifest.Assembly != nil {
+		if parts, err = getAssemblyParts(inputFilename, frames, renderManifest.Assembly); err != nil {
+			log.Fatal(err)
+		}
+	}
 
-	// Check if there are files to output
-	for _, scale := range splitScales {
-		timingutils.Time(fmt.Sprintf("Total (%sx)", scale), flags.OutputTime, func() {
-			renderScale(inputFilename, scale, renderManifest, processedObject, palette, numScales)
-		})
+	for _, part := range parts {
+		processedFrames := make([]voxelobject.ProcessedVoxelObject, len(part.frames))
+		for i := range part.frames {
+			timingutils.Time("Voxel processing", flags.OutputTime, func() {
+				processedFrames[i] = voxelobject.GetProcessedVoxelObject(part.frames[i], &palette, renderManifest.TiledNormals, renderManifest.TilingMode, renderManifest.SolidBase)
+			})
+
+			if renderManifest.OcclusionMode == "raytraced" {
+				timingutils.Time("Ambient occlusion", flags.OutputTime, func() {
+					processedFrames[i].CalculateAmbientOcclusion(renderManifest.OcclusionRays, renderManifest.OcclusionDistance)
+				})
+			}
+		}
+
+		// Check if there are files to output
+		for _, scale := range splitScales {
+			timingutils.Time(fmt.Sprintf("Total (%sx)", scale), flags.OutputTime, func() {
+				renderScale(getOutputFilename(inputFilename, part.name, scale, numScales), scale, renderManifest, processedFrames, palette, targetPalette)
+			})
+		}
+	}
+
+	if renderManifest.Assembly != nil {
+		for _, scale := range splitScales {
+			if err := writeAssemblyOffsets(getOutputFilename(inputFilename, "", scale, numScales), scale, renderManifest, frames[0], parts); err != nil {
+				log.Fatal(err)
+			}
+		}
 	}
 
 	if flags.ProgressIndicator {
@@ -177,36 +278,86 @@ func processFile(inputFilename string) {
 
 }
 
-func allPotentialOutputFilesExist(inputFilename string, scale string, numScales int, manifestFilepath string) (bool, error) {
+func allPotentialOutputFilesExist(inputFilename string, scale string, numScales int, manifestFilepath string, m manifest.Manifest) (bool, error) {
 	// Always overwrite files if the flag is set
 	if flags.Overwrite {
 		return false, nil
 	}
 
-	outputFilename := getOutputFilename(inputFilename, scale, numScales)
-
-	inputFileStats, err := os.Stat(inputFilename)
+	manifestFileStats, err := os.Stat(manifestFilepath)
 	if err != nil {
 		return false, err
 	}
 
-	manifestFileStats, err := os.Stat(manifestFilepath)
-	if err != nil {
-		return false, err
+	modTime := manifestFileStats.ModTime()
+	for _, f := range getInputFilenames(inputFilename, m) {
+		inputFileStats, err := os.Stat(f)
+		if os.IsNotExist(err) && f != inputFilename {
+			// Let rendering report the missing file
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+
+		if inputFileStats.ModTime().After(modTime) {
+			modTime = inputFileStats.ModTime()
+		}
 	}
 
 	check := []string{"8bpp"}
 	if !flags.Output8bppOnly {
 		check = []string{"8bpp", "32bpp", "mask"}
-	}
 
-	modTime := inputFileStats.ModTime()
-	if manifestFileStats.ModTime().After(modTime) {
-		modTime = manifestFileStats.ModTime()
+		if flags.CompanyColourPreview {
+			check = append(check, "cc_preview")
+		}
+
+		if m.GroundShadow {
+			check = append(check, "ground_shadow")
+		}
 	}
 
+	suffixes := make([]string, 0, len(check)+1)
 	for _, f := range check {
-		newer, err := fileIsNewerThanDate(outputFilename+"_"+f+".png", modTime)
+		suffixes = append(suffixes, "_"+f+".png")
+	}
+
+	if flags.Turntable > 0 {
+		suffixes = append(suffixes, "_turntable.gif")
+	}
+
+	if hasMultipleFrames(m) {
+		suffixes = append(suffixes, "_frames.json")
+	}
+
+	if m.Tiles != nil {
+		suffixes = append(suffixes, "_tiles.json")
+	}
+
+	baseFilename := getOutputFilename(inputFilename, "", scale, numScales)
+	outputFilenames := []string{baseFilename}
+	if m.Assembly != nil {
+		outputFilenames = nil
+		for i, p := range m.Assembly.Parts {
+			outputFilenames = append(outputFilenames, getOutputFilename(inputFilename, getPartName(i, p), scale, numScales))
+		}
+	}
+
+	var filenames []string
+	for _, outputFilename := range outputFilenames {
+		for _, suffix := range suffixes {
+			filenames = append(filenames, outputFilename+suffix)
+		}
+	}
+
+	// Part offsets are written once for the whole assembly
+	if m.Assembly != nil {
+		filenames = append(filenames, baseFilename+"_parts.json")
+	}
+
+	for _, f := range filenames {
+		newer, err := fileIsNewerThanDate(f, modTime)
 		if err != nil {
 			return false, err
 		}
@@ -219,6 +370,35 @@ func allPotentialOutputFilesExist(inputFilename string, scale string, numScales
 	return true, nil
 }
 
+// Check whether the manifest renders more than one frame, which means frame
+// metadata is written. Animations using every model in the file are assumed
+// to have more than one.
+func hasMultipleFrames(m manifest.Manifest) bool {
+	return m.Slopes || (m.Animation != nil && m.Animation.Frames != 1)
+}
+
+// Get every voxel file used to render the input file, including animation
+// frames and assembly parts in other files
+func getInputFilenames(inputFilename string, m manifest.Manifest) []string {
+	filenames := []string{inputFilename}
+
+	if m.Animation != nil && m.Animation.GetSource() == manifest.AnimationSourceFiles {
+		for n := 1; n < m.Animation.Frames; n++ {
+			filenames = append(filenames, getFrameFilename(inputFilename, n))
+		}
+	}
+
+	if m.Assembly != nil {
+		for _, p := range m.Assembly.Parts {
+			if p.File != "" {
+				filenames = append(filenames, getPartFilename(inputFilename, p))
+			}
+		}
+	}
+
+	return filenames
+}
+
 func fileIsNewerThanDate(filename string, date time.Time) (bool, error) {
 	fileStats, err := os.Stat(filename)
 
@@ -237,7 +417,7 @@ func fileIsNewerThanDate(filename string, date time.Time) (bool, error) {
 	return false, nil
 }
 
-func renderScale(inputFilename string, scale string, m manifest.Manifest, processedObject voxelobject.ProcessedVoxelObject, palette colour.Palette, numScales int) {
+func renderScale(outputFilename string, scale string, m manifest.Manifest, frames []voxelobject.ProcessedVoxelObject, palette colour.Palette, targetPalette *colour.Palette) {
 	if flags.OutputTime {
 		fmt.Printf("\n=== Scale %sx ===\n", scale)
 	}
@@ -249,18 +429,34 @@ func renderScale(inputFilename string, scale string, m manifest.Manifest, proces
 	}
 
 	def := manifest.Definition{
-		Object:   processedObject,
-		Manifest: m,
-		Palette:  palette,
-		Scale:    scaleF,
-		Debug:    flags.Debug,
-		Time:     flags.OutputTime,
-		Only8bpp: flags.Output8bppOnly,
+		Object:        frames[0],
+		Manifest:      m,
+		Palette:       palette,
+		TargetPalette: targetPalette,
+		Scale:         scaleF,
+		Debug:         flags.Debug,
+		Time:          flags.OutputTime,
+		Only8bpp:      flags.Output8bppOnly,
+
+		CompanyColourPreview:        flags.CompanyColourPreview,
+		CompanyColourPreviewColours: getCompanyColourPreviewColours(),
+		AnimationPreview:            flags.AnimationPreview,
+		Turntable:                   flags.Turntable,
+		TurntableScale:              flags.TurntableScale,
+		TurntableFrames:             flags.TurntableFrames,
 	}
 
-	sheets := spritesheet.GetSpritesheets(def)
+	if len(frames) > 1 {
+		def.Frames = frames
+	}
+
+	if def.Turntable > 0 {
+		if _, err := def.GetTurntableSprites(); err != nil {
+			log.Fatal(err)
+		}
+	}
 
-	outputFilename := getOutputFilename(inputFilename, scale, numScales)
+	sheets := spritesheet.GetSpritesheets(def)
 
 	timingutils.Time("PNG output", flags.OutputTime, func() {
 		if err := sheets.SaveAll(outputFilename); err != nil {
@@ -269,7 +465,17 @@ func renderScale(inputFilename string, scale string, m manifest.Manifest, proces
 	})
 }
 
-func getOutputFilename(inputFilename string, scale string, numScales int) string {
+func getCompanyColourPreviewColours() (colours []string) {
+	for _, c := range strings.Split(flags.CompanyColourPreviewColours, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			colours = append(colours, c)
+		}
+	}
+
+	return
+}
+
+func getOutputFilename(inputFilename string, part string, scale string, numScales int) string {
 	var outputFilename string
 
 	if flags.StripDirectory {
@@ -282,6 +488,10 @@ func getOutputFilename(inputFilename string, scale string, numScales int) string
 		outputFilename = fileutils.GetBaseFilename(flags.OutputFilename)
 	}
 
+	if part != "" {
+		outputFilename += "_" + part
+	}
+
 	outputFilename += flags.Suffix
 
 	if numScales > 1 || flags.SubDirs {
@@ -311,9 +521,152 @@ func setupFlags() error {
 	return nil
 }
 
-func getPalette(filename string) (palette colour.Palette, err error) {
-	err = fileutils.InstantiateFromFile(filename, &palette)
-	return
+func getPalette(filename string, rangesFilename string) (palette colour.Palette, err error) {
+	format := colour.GetPaletteFormat(filename)
+
+	if format == colour.PaletteFormatJSON {
+		err = fileutils.InstantiateFromFile(filename, &palette)
+		return
+	}
+
+	colours := colour.PaletteColours{Format: format}
+	if err = fileutils.InstantiateFromFile(filename, &colours); err != nil {
+		return
+	}
+
+	return getPaletteWithRanges(colours.Entries, rangesFilename)
+}
+
+// Colours which are further than this (in CIEDE2000) from the closest palette
+// colour are reported when remapping
+const remapWarningDifference = 10.0
+
+// Recolour an object to the closest renderable colours in the palette
+func remapObject(inputFilename string, object magica.VoxelObject, palette colour.Palette, distance string) (magica.VoxelObject, error) {
+	source, err := getVoxPalette(object, flags.VoxPaletteRangesFile)
+	if err != nil {
+		return object, err
+	}
+
+	remap := source.GetRemap(palette, distance)
+	counts := voxelobject.GetColourCounts(object)
+
+	for i, count := range counts {
+		if count == 0 || remap.Difference[i] <= remapWarningDifference {
+			continue
+		}
+
+		from, to := source.Entries[i], palette.Entries[remap.IndexMap[i]]
+		fmt.Printf("%s: colour %d (%d,%d,%d) used by %d voxels has no close match, nearest is palette index %d (%d,%d,%d) with difference %.1f\n",
+			inputFilename, byte(i+2), from.R, from.G, from.B, count, remap.IndexMap[i], to.R, to.G, to.B, remap.Difference[i])
+	}
+
+	result := voxelobject.RemapColours(object, remap.IndexMap)
+	voxelobject.SetPaletteEntries(&result, palette.Entries)
+	return result, nil
+}
+
+// Get the objects to render for each frame of the animation or each slope,
+// or just the object itself if there is neither
+func getFrames(inputFilename string, object magica.VoxelObject, m manifest.Manifest) ([]magica.VoxelObject, error) {
+	animation := m.Animation
+	if m.Slopes {
+		if animation != nil {
+			return nil, fmt.Errorf("slopes cannot be used with animation")
+		}
+
+		return transform.GetSlopes(object, m.SlopeHeight), nil
+	}
+
+	if animation == nil {
+		return []magica.VoxelObject{object}, nil
+	}
+
+	frames, err := getAnimationSource(inputFilename, object, animation)
+	if err != nil {
+		return nil, err
+	}
+
+	return transform.AnimateParts(frames, animation.Parts)
+}
+
+func getAnimationSource(inputFilename string, object magica.VoxelObject, animation *manifest.Animation) ([]magica.VoxelObject, error) {
+	switch animation.GetSource() {
+	case manifest.AnimationSourceModels:
+		models, err := voxelobject.ModelsFromFile(inputFilename)
+		if err != nil {
+			return nil, err
+		}
+
+		if animation.Frames > 0 && animation.Frames < len(models) {
+			models = models[:animation.Frames]
+		}
+
+		return models, nil
+	case manifest.AnimationSourceFiles:
+		frames := []magica.VoxelObject{object}
+
+		for n := 1; n < animation.Frames; n++ {
+			frame, err := magica.FromFile(getFrameFilename(inputFilename, n))
+			if err != nil {
+				return nil, fmt.Errorf("could not load animation frame %d: %v", n, err)
+			}
+
+			frames = append(frames, frame)
+		}
+
+		return voxelobject.PadToSameSize(frames), nil
+	case manifest.AnimationSourceParts:
+		if animation.Frames < 1 {
+			return nil, fmt.Errorf("animating parts needs at least 1 frame")
+		}
+
+		frames := make([]magica.VoxelObject, animation.Frames)
+		for i := range frames {
+			frames[i] = object
+		}
+
+		return frames, nil
+	}
+
+	return nil, fmt.Errorf("unknown animation source %q", animation.Source)
+}
+
+func getFrameFilename(inputFilename string, frame int) string {
+	return fmt.Sprintf("%s_%d.vox", fileutils.GetBaseFilename(inputFilename), frame)
+}
+
+func getRemappedFilename(inputFilename string) string {
+	if flags.StripDirectory {
+		inputFilename = filepath.Base(inputFilename)
+	}
+
+	if flags.OutputFilename != "" {
+		inputFilename = flags.OutputFilename
+	}
+
+	return fileutils.GetBaseFilename(inputFilename) + flags.Suffix + "_remapped.vox"
+}
+
+func getVoxPalette(object magica.VoxelObject, rangesFilename string) (palette colour.Palette, err error) {
+	entries, err := voxelobject.GetPaletteEntries(object)
+	if err != nil {
+		return
+	}
+
+	return getPaletteWithRanges(entries, rangesFilename)
+}
+
+func getPaletteWithRanges(entries []colour.PaletteEntry, rangesFilename string) (palette colour.Palette, err error) {
+	// Ranges are derived from the colours if there is no ranges file
+	settings := colour.PaletteSettings{}
+	if rangesFilename != "" {
+		if err = fileutils.InstantiateFromFile(rangesFilename, &settings); err != nil {
+			return
+		}
+	}
+
+	return colour.FromColours(entries, settings.Palette)
 }
 
 func getManifest(filename string) (manifest manifest.Manifest, err error) {
@@ -0,0 +1,107 @@
+package colour
+
+// Limit on the length of a palette animation, in case the cycle lengths
+// have no small common multiple
+const maxAnimationFrames = 256
+
+const defaultAnimationFrameDelay = 5
+
+// GetAnimationFrameDelay returns the time each frame of the palette animation
+// is shown for, in hundredths of a second
+func (p Palette) GetAnimationFrameDelay() int {
+	if p.AnimationFrameDelay <= 0 {
+		return defaultAnimationFrameDelay
+	}
+
+	return p.AnimationFrameDelay
+}
+
+// GetAnimationLength returns the number of frames before every animated range
+// used by the colours at indexes is back to its starting colours. Ranges which
+// aren't used don't make the animation any longer.
+func (p Palette) GetAnimationLength(indexes []byte) (length int) {
+	length = 1
+
+	for _, r := range p.Ranges {
+		if !r.IsAnimatedLight || !r.containsAny(indexes) {
+			continue
+		}
+
+		length = lcm(length, getCycleLength(r))
+		if length > maxAnimationFrames {
+			return maxAnimationFrames
+		}
+	}
+
+	return
+}
+
+// GetAnimationFrame returns the palette colours at a frame of the palette
+// animation. The colours of each animated range rotate by one position every
+// animation_speed frames, with negative speeds rotating the other way.
+func (p Palette) GetAnimationFrame(frame int) (entries []PaletteEntry) {
+	entries = make([]PaletteEntry, len(p.Entries))
+	copy(entries, p.Entries)
+
+	for _, r := range p.Ranges {
+		if !r.IsAnimatedLight {
+			continue
+		}
+
+		speed, n := r.AnimationSpeed, int(r.End)-int(r.Start)+1
+		if speed == 0 {
+			speed = 1
+		}
+
+		step := frame / abs(speed)
+		if speed < 0 {
+			step = -step
+		}
+
+		for i := 0; i < n; i++ {
+			entries[int(r.Start)+i] = p.Entries[int(r.Start)+mod(i+step, n)]
+		}
+	}
+
+	return
+}
+
+func (r PaletteRange) containsAny(indexes []byte) bool {
+	for _, i := range indexes {
+		if i >= r.Start && i <= r.End {
+			return true
+		}
+	}
+
+	return false
+}
+
+func getCycleLength(r PaletteRange) int {
+	speed := abs(r.AnimationSpeed)
+	if speed == 0 {
+		speed = 1
+	}
+
+	return (int(r.End) - int(r.Start) + 1) * speed
+}
+
+func lcm(a, b int) int {
+	x, y := a, b
+	for y != 0 {
+		x, y = y, x%y
+	}
+
+	return a / x * b
+}
+
+func abs(a int) int {
+	if a < 0 {
+		return -a
+	}
+
+	return a
+}
+
+func mod(a, n int) int {
+	return ((a % n) + n) % n
+}
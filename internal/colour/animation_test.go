@@ -0,0 +1,51 @@
+package colour
+
+import "testing"
+
+func TestPalette_GetAnimationFrame(t *testing.T) {
+	p := Palette{Entries: []PaletteEntry{{}, {R: 1}, {R: 2}, {R: 3}, {R: 4}, {R: 5}, {R: 6}}}
+	p.SetRanges([]PaletteRange{
+		{Start: 1, End: 3, IsAnimatedLight: true},
+		{Start: 4, End: 5, IsAnimatedLight: true, AnimationSpeed: -2},
+		{Start: 6, End: 6},
+	})
+
+	lengthCases := []struct {
+		indexes  []byte
+		expected int
+	}{
+		{[]byte{1, 4, 6}, 12},
+		{[]byte{2}, 3},
+		{[]byte{5, 5}, 4},
+		{[]byte{6}, 1},
+	}
+
+	for _, testCase := range lengthCases {
+		if length := p.GetAnimationLength(testCase.indexes); length != testCase.expected {
+			t.Errorf("expected animation length %d for colours %v, got %d", testCase.expected, testCase.indexes, length)
+		}
+	}
+
+	testCases := []struct {
+		frame    int
+		expected []byte
+	}{
+		{0, []byte{0, 1, 2, 3, 4, 5, 6}},
+		{1, []byte{0, 2, 3, 1, 4, 5, 6}},
+		{2, []byte{0, 3, 1, 2, 5, 4, 6}},
+		{12, []byte{0, 1, 2, 3, 4, 5, 6}},
+	}
+
+	for _, testCase := range testCases {
+		entries := p.GetAnimationFrame(testCase.frame)
+		for i, e := range testCase.expected {
+			if entries[i].R != e {
+				t.Errorf("frame %d colour %d expected %d, got %d", testCase.frame, i, e, entries[i].R)
+			}
+		}
+	}
+
+	if p.Entries[1].R != 1 {
+		t.Errorf("animating the palette changed the original colours")
+	}
+}
@@ -0,0 +1,94 @@
+package colour
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CompanyColour is one of the colours a company can choose in OpenTTD. The
+// colour is a run of palette entries starting from Start, the same length as
+// the company colour range it replaces.
+type CompanyColour struct {
+	Name  string `json:"name"`
+	Start byte   `json:"start"`
+}
+
+// GetCompanyColour returns the company colour with the given name, ignoring
+// case and treating spaces as underscores
+func (p Palette) GetCompanyColour(name string) (CompanyColour, error) {
+	name = strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), " ", "_")
+
+	for _, cc := range p.CompanyColours {
+		if strings.ToLower(cc.Name) == name {
+			return cc, nil
+		}
+	}
+
+	return CompanyColour{}, fmt.Errorf("palette has no company colour %q", name)
+}
+
+// GetCompanyColourPairs returns the primary and secondary company colours to
+// preview. With no names every company colour is used for both, one name sets
+// both colours, and two names set the primary and secondary colour.
+func (p Palette) GetCompanyColourPairs(names []string) (pairs [][2]CompanyColour, err error) {
+	if len(p.CompanyColours) == 0 {
+		return nil, fmt.Errorf("palette does not define any company colours")
+	}
+
+	switch len(names) {
+	case 0:
+		for _, cc := range p.CompanyColours {
+			pairs = append(pairs, [2]CompanyColour{cc, cc})
+		}
+		return pairs, nil
+	case 1, 2:
+		primary, err := p.GetCompanyColour(names[0])
+		if err != nil {
+			return nil, err
+		}
+
+		secondary := primary
+		if len(names) == 2 {
+			if secondary, err = p.GetCompanyColour(names[1]); err != nil {
+				return nil, err
+			}
+		}
+
+		return [][2]CompanyColour{{primary, secondary}}, nil
+	}
+
+	return nil, fmt.Errorf("expected a primary and optional secondary company colour, got %d colours", len(names))
+}
+
+// GetCompanyColourRemap returns a map from each palette index to the index
+// used when the company colours are the given primary and secondary colours.
+// Indexes outside the company colour ranges are unchanged.
+func (p Palette) GetCompanyColourRemap(primary, secondary CompanyColour) (remap [256]byte) {
+	for i := range remap {
+		remap[i] = byte(i)
+
+		if i >= len(p.Entries) || p.Entries[i].Range == nil {
+			continue
+		}
+
+		rng := p.Entries[i].Range
+		offset := i - int(rng.Start)
+
+		if rng.IsPrimaryCompanyColour {
+			remap[i] = getCompanyColourIndex(primary, offset, len(p.Entries))
+		} else if rng.IsSecondaryCompanyColour {
+			remap[i] = getCompanyColourIndex(secondary, offset, len(p.Entries))
+		}
+	}
+
+	return
+}
+
+func getCompanyColourIndex(cc CompanyColour, offset int, size int) byte {
+	index := int(cc.Start) + offset
+	if index >= size {
+		index = size - 1
+	}
+
+	return byte(index)
+}
@@ -0,0 +1,234 @@
+package colour
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	PaletteFormatJSON = "json"
+	PaletteFormatJASC = "pal"
+	PaletteFormatGIMP = "gpl"
+	PaletteFormatACT  = "act"
+	PaletteFormatPNG  = "png"
+)
+
+const maxPaletteSize = 256
+
+// GetPaletteFormat returns the palette format for a file based on its
+// extension. Unrecognised extensions are assumed to be gorender JSON.
+func GetPaletteFormat(filename string) string {
+	switch ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(filename), ".")); ext {
+	case PaletteFormatJASC, PaletteFormatGIMP, PaletteFormatACT, PaletteFormatPNG:
+		return ext
+	}
+
+	return PaletteFormatJSON
+}
+
+// PaletteColours is the list of colours read from a palette file in one of the
+// formats used by other tools, which don't include any range information.
+type PaletteColours struct {
+	Format  string
+	Entries []PaletteEntry
+}
+
+func (c *PaletteColours) GetFromReader(handle io.Reader) (err error) {
+	switch c.Format {
+	case PaletteFormatJASC:
+		c.Entries, err = readJASC(handle)
+	case PaletteFormatGIMP:
+		c.Entries, err = readGIMP(handle)
+	case PaletteFormatACT:
+		c.Entries, err = readACT(handle)
+	case PaletteFormatPNG:
+		c.Entries, err = readPNG(handle)
+	default:
+		return fmt.Errorf("unsupported palette format %q", c.Format)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if len(c.Entries) > maxPaletteSize {
+		return fmt.Errorf("palette has %d colours, the maximum is %d", len(c.Entries), maxPaletteSize)
+	}
+
+	// Pad short palettes so every voxel index has an entry
+	for len(c.Entries) < maxPaletteSize {
+		c.Entries = append(c.Entries, PaletteEntry{})
+	}
+
+	return nil
+}
+
+// PaletteSettings holds everything in a gorender palette file other than the
+// colours. This allows ranges and lighting settings to be kept alongside a
+// palette stored in another format.
+type PaletteSettings struct {
+	Palette
+}
+
+func (s *PaletteSettings) GetFromReader(handle io.Reader) error {
+	data, err := io.ReadAll(handle)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, &s.Palette)
+}
+
+// FromColours returns a palette with the supplied colours and settings. If the
+// settings have no ranges, they are derived from the colours.
+func FromColours(entries []PaletteEntry, settings Palette) (p Palette, err error) {
+	p = settings
+	p.Entries = entries
+
+	if len(p.Ranges) == 0 {
+		p.Ranges = DeriveRanges(entries)
+	}
+
+	if err = p.SetRanges(p.Ranges); err != nil {
+		return Palette{}, err
+	}
+
+	return
+}
+
+// JASC (Paint Shop Pro) palettes are a text header followed by one
+// "r g b" line per colour
+func readJASC(handle io.Reader) (entries []PaletteEntry, err error) {
+	scanner := bufio.NewScanner(handle)
+	var lines []string
+
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(lines) < 3 || lines[0] != "JASC-PAL" {
+		return nil, fmt.Errorf("not a JASC palette file")
+	}
+
+	count, err := strconv.Atoi(lines[2])
+	if err != nil || count < 0 || count > maxPaletteSize {
+		return nil, fmt.Errorf("invalid JASC palette colour count %q", lines[2])
+	}
+
+	if len(lines)-3 < count {
+		return nil, fmt.Errorf("JASC palette has %d colours, expected %d", len(lines)-3, count)
+	}
+
+	for _, line := range lines[3 : 3+count] {
+		entry, err := parseEntry(strings.Fields(line))
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return
+}
+
+// GIMP palettes are a text header followed by one "r g b name" line per
+// colour, with optional comments
+func readGIMP(handle io.Reader) (entries []PaletteEntry, err error) {
+	scanner := bufio.NewScanner(handle)
+
+	if !scanner.Scan() || strings.TrimSpace(scanner.Text()) != "GIMP Palette" {
+		return nil, fmt.Errorf("not a GIMP palette file")
+	}
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "Name:") || strings.HasPrefix(line, "Columns:") {
+			continue
+		}
+
+		entry, err := parseEntry(strings.Fields(line))
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, scanner.Err()
+}
+
+func parseEntry(fields []string) (entry PaletteEntry, err error) {
+	if len(fields) < 3 {
+		return entry, fmt.Errorf("invalid palette colour %q", strings.Join(fields, " "))
+	}
+
+	values := make([]byte, 3)
+	for i := range values {
+		v, err := strconv.Atoi(fields[i])
+		if err != nil || v < 0 || v > 255 {
+			return entry, fmt.Errorf("invalid palette colour %q", strings.Join(fields, " "))
+		}
+		values[i] = byte(v)
+	}
+
+	return PaletteEntry{R: values[0], G: values[1], B: values[2]}, nil
+}
+
+// Adobe colour tables are 256 RGB triples, optionally followed by the number
+// of colours in use and the transparent index
+func readACT(handle io.Reader) (entries []PaletteEntry, err error) {
+	data, err := io.ReadAll(handle)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < maxPaletteSize*3 {
+		return nil, fmt.Errorf("ACT palette is %d bytes, expected at least %d", len(data), maxPaletteSize*3)
+	}
+
+	count := maxPaletteSize
+	if len(data) >= (maxPaletteSize*3)+2 {
+		if c := int(binary.BigEndian.Uint16(data[maxPaletteSize*3:])); c > 0 && c <= maxPaletteSize {
+			count = c
+		}
+	}
+
+	for i := 0; i < count; i++ {
+		entries = append(entries, PaletteEntry{R: data[i*3], G: data[i*3+1], B: data[i*3+2]})
+	}
+
+	return
+}
+
+// The palette of an indexed PNG image
+func readPNG(handle io.Reader) (entries []PaletteEntry, err error) {
+	img, err := png.Decode(handle)
+	if err != nil {
+		return nil, err
+	}
+
+	paletted, ok := img.(*image.Paletted)
+	if !ok {
+		return nil, fmt.Errorf("PNG palette file is not an indexed colour image")
+	}
+
+	for _, c := range paletted.Palette {
+		nrgba := color.NRGBAModel.Convert(c).(color.NRGBA)
+		entries = append(entries, PaletteEntry{R: nrgba.R, G: nrgba.G, B: nrgba.B})
+	}
+
+	return
+}
@@ -0,0 +1,170 @@
+package colour
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+var expectedFormatEntries = []PaletteEntry{{R: 0, G: 0, B: 255}, {R: 16, G: 32, B: 48}, {R: 255, G: 255, B: 255}}
+
+func TestGetPaletteFormat(t *testing.T) {
+	testCases := map[string]string{
+		"files/ttd_palette.json": PaletteFormatJSON,
+		"palette.PAL":            PaletteFormatJASC,
+		"dir.gpl/palette.gpl":    PaletteFormatGIMP,
+		"palette.act":            PaletteFormatACT,
+		"palette.png":            PaletteFormatPNG,
+		"palette":                PaletteFormatJSON,
+	}
+
+	for filename, expected := range testCases {
+		if result := GetPaletteFormat(filename); result != expected {
+			t.Errorf("Format for %s expected %s, got %s", filename, expected, result)
+		}
+	}
+}
+
+func TestPaletteColours_GetFromReader(t *testing.T) {
+	act := make([]byte, 772)
+	for i, e := range expectedFormatEntries {
+		act[i*3], act[i*3+1], act[i*3+2] = e.R, e.G, e.B
+	}
+	binary.BigEndian.PutUint16(act[768:], 3)
+
+	img := image.NewPaletted(image.Rect(0, 0, 1, 1), color.Palette{
+		color.RGBA{B: 255, A: 255}, color.RGBA{R: 16, G: 32, B: 48, A: 255}, color.RGBA{R: 255, G: 255, B: 255, A: 255},
+	})
+	pngData := bytes.Buffer{}
+	if err := png.Encode(&pngData, img); err != nil {
+		t.Fatalf("could not encode test PNG: %v", err)
+	}
+
+	testCases := []struct {
+		format string
+		data   []byte
+	}{
+		{PaletteFormatJASC, []byte("JASC-PAL\r\n0100\r\n3\r\n0 0 255\r\n16 32 48\r\n255 255 255\r\n")},
+		{PaletteFormatGIMP, []byte("GIMP Palette\nName: Test\nColumns: 4\n#\n  0   0 255\tBlue\n 16  32  48\tUntitled\n255 255 255\tWhite\n")},
+		{PaletteFormatACT, act},
+		{PaletteFormatPNG, pngData.Bytes()},
+	}
+
+	for _, testCase := range testCases {
+		colours := PaletteColours{Format: testCase.format}
+		if err := colours.GetFromReader(bytes.NewReader(testCase.data)); err != nil {
+			t.Errorf("%s: error reading palette: %v", testCase.format, err)
+			continue
+		}
+
+		if len(colours.Entries) != maxPaletteSize {
+			t.Errorf("%s: expected %d entries, got %d", testCase.format, maxPaletteSize, len(colours.Entries))
+			continue
+		}
+
+		for i, e := range expectedFormatEntries {
+			if colours.Entries[i] != e {
+				t.Errorf("%s: entry %d expected %v, got %v", testCase.format, i, e, colours.Entries[i])
+			}
+		}
+
+		if colours.Entries[len(expectedFormatEntries)] != (PaletteEntry{}) {
+			t.Errorf("%s: expected padding entries to be black", testCase.format)
+		}
+	}
+}
+
+func TestPaletteColours_GetFromReader_Errors(t *testing.T) {
+	testCases := []struct {
+		format string
+		data   string
+	}{
+		{PaletteFormatJASC, "JASC-PAL\n0100\n2\n0 0 0\n"},
+		{PaletteFormatJASC, "not a palette"},
+		{PaletteFormatJASC, "JASC-PAL\n0100\n1\n0 0 256\n"},
+		{PaletteFormatJASC, "JASC-PAL\n0100\n-1\n0 0 0\n"},
+		{PaletteFormatJASC, "JASC-PAL\n0100\n257\n0 0 0\n"},
+		{PaletteFormatGIMP, "GIMP Palette\n0 0\n"},
+		{PaletteFormatACT, "too short"},
+		{PaletteFormatPNG, "not a png"},
+		{"bmp", ""},
+	}
+
+	for _, testCase := range testCases {
+		colours := PaletteColours{Format: testCase.format}
+		if err := colours.GetFromReader(strings.NewReader(testCase.data)); err == nil {
+			t.Errorf("%s: expected error reading %q", testCase.format, testCase.data)
+		}
+	}
+}
+
+func TestFromColours(t *testing.T) {
+	entries := make([]PaletteEntry, 8)
+
+	settings := PaletteSettings{}
+	if err := settings.GetFromReader(strings.NewReader(`{"company_colour_lighting_scale": 2.0, "ranges": [{"start": 1, "end": 3, "is_primary_company_colour": true}]}`)); err != nil {
+		t.Fatalf("error reading settings: %v", err)
+	}
+
+	p, err := FromColours(entries, settings.Palette)
+	if err != nil {
+		t.Fatalf("error creating palette: %v", err)
+	}
+
+	if p.CompanyColourLightingScale != 2.0 {
+		t.Errorf("expected lighting settings to be kept")
+	}
+
+	if !p.IsSpecialColour(2) || p.IsSpecialColour(4) {
+		t.Errorf("expected ranges from settings to be applied")
+	}
+
+	// Ranges outside the palette
+	settings.Palette.Ranges = []PaletteRange{{Start: 4, End: 8}}
+	if _, err := FromColours(entries, settings.Palette); err == nil {
+		t.Errorf("expected error for range outside palette")
+	}
+
+	// Derived ranges
+	p, err = FromColours(entries, Palette{})
+	if err != nil {
+		t.Fatalf("error creating palette: %v", err)
+	}
+
+	for i := 1; i < len(entries); i++ {
+		if p.Entries[i].Range == nil {
+			t.Errorf("expected derived range for entry %d", i)
+		}
+	}
+}
+
+func TestDeriveRanges(t *testing.T) {
+	entries := []PaletteEntry{
+		{R: 255, G: 0, B: 255},
+		// Greys
+		{R: 32, G: 32, B: 32}, {R: 64, G: 64, B: 64}, {R: 128, G: 128, B: 128},
+		// Blues
+		{R: 0, G: 0, B: 96}, {R: 16, G: 16, B: 160}, {R: 64, G: 64, B: 255},
+		// Darker blue, so a new range
+		{R: 0, G: 0, B: 64},
+		// Red
+		{R: 192, G: 0, B: 0},
+	}
+
+	expected := []PaletteRange{{Start: 1, End: 3}, {Start: 4, End: 6}, {Start: 7, End: 7}, {Start: 8, End: 8}}
+	result := DeriveRanges(entries)
+
+	if len(result) != len(expected) {
+		t.Fatalf("expected %d ranges, got %d: %v", len(expected), len(result), result)
+	}
+
+	for i := range expected {
+		if result[i].Start != expected[i].Start || result[i].End != expected[i].End {
+			t.Errorf("range %d expected %d-%d, got %d-%d", i, expected[i].Start, expected[i].End, result[i].Start, result[i].End)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package colour
+
+import "math"
+
+// Lab is a colour in a perceptual colour space (CIELAB or OKLab) where L is
+// lightness and A and B are the green-red and blue-yellow axes.
+type Lab struct {
+	L, A, B float64
+}
+
+// D65 reference white
+const (
+	whiteX = 0.95047
+	whiteY = 1.0
+	whiteZ = 1.08883
+)
+
+// RGB values are treated as sRGB encoded in the range [0,65535]
+func toLinear(value float64) float64 {
+	v := Clamp(value/65535, 0, 1)
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func (rgb RGB) linear() (r, g, b float64) {
+	return toLinear(rgb.R), toLinear(rgb.G), toLinear(rgb.B)
+}
+
+// ToOKLab converts a colour to OKLab (Björn Ottosson, 2020)
+func (rgb RGB) ToOKLab() Lab {
+	r, g, b := rgb.linear()
+
+	l := math.Cbrt(0.4122214708*r + 0.5363325363*g + 0.0514459929*b)
+	m := math.Cbrt(0.2119034982*r + 0.6806995451*g + 0.1073969566*b)
+	s := math.Cbrt(0.0883024619*r + 0.2817188376*g + 0.6299787005*b)
+
+	return Lab{
+		L: 0.2104542553*l + 0.7936177850*m - 0.0040720468*s,
+		A: 1.9779984951*l - 2.4285922050*m + 0.4505937099*s,
+		B: 0.0259040371*l + 0.7827717662*m - 0.8086757660*s,
+	}
+}
+
+// ToCIELab converts a colour to CIELAB with a D65 white point
+func (rgb RGB) ToCIELab() Lab {
+	r, g, b := rgb.linear()
+
+	x := (0.4124564*r + 0.3575761*g + 0.1804375*b) / whiteX
+	y := (0.2126729*r + 0.7151522*g + 0.0721750*b) / whiteY
+	z := (0.0193339*r + 0.1191920*g + 0.9503041*b) / whiteZ
+
+	fx, fy, fz := labF(x), labF(y), labF(z)
+
+	return Lab{
+		L: 116*fy - 16,
+		A: 500 * (fx - fy),
+		B: 200 * (fy - fz),
+	}
+}
+
+func labF(t float64) float64 {
+	const delta = 6.0 / 29
+	if t > delta*delta*delta {
+		return math.Cbrt(t)
+	}
+
+	return t/(3*delta*delta) + 4.0/29
+}
+
+// Distance returns the Euclidean distance between two colours, which is the
+// perceptual difference for OKLab colours
+func (lab Lab) Distance(other Lab) float64 {
+	dl, da, db := lab.L-other.L, lab.A-other.A, lab.B-other.B
+	return math.Sqrt(dl*dl + da*da + db*db)
+}
+
+// DeltaE2000 returns the CIEDE2000 colour difference between two CIELAB colours
+func (lab Lab) DeltaE2000(other Lab) float64 {
+	const pow25to7 = 6103515625.0
+
+	c1 := math.Hypot(lab.A, lab.B)
+	c2 := math.Hypot(other.A, other.B)
+	cBar7 := math.Pow((c1+c2)/2, 7)
+	g := 0.5 * (1 - math.Sqrt(cBar7/(cBar7+pow25to7)))
+
+	a1, a2 := (1+g)*lab.A, (1+g)*other.A
+	c1, c2 = math.Hypot(a1, lab.B), math.Hypot(a2, other.B)
+	h1, h2 := hueAngle(lab.B, a1), hueAngle(other.B, a2)
+
+	dL := other.L - lab.L
+	dC := c2 - c1
+
+	dh := 0.0
+	if c1*c2 != 0 {
+		dh = h2 - h1
+		if dh > 180 {
+			dh -= 360
+		} else if dh < -180 {
+			dh += 360
+		}
+	}
+	dH := 2 * math.Sqrt(c1*c2) * math.Sin(degToRad(dh/2))
+
+	lBar := (lab.L + other.L) / 2
+	cBar := (c1 + c2) / 2
+
+	hBar := h1 + h2
+	if c1*c2 != 0 {
+		if math.Abs(h1-h2) <= 180 {
+			hBar = hBar / 2
+		} else if hBar < 360 {
+			hBar = (hBar + 360) / 2
+		} else {
+			hBar = (hBar - 360) / 2
+		}
+	}
+
+	t := 1 - 0.17*math.Cos(degToRad(hBar-30)) +
+		0.24*math.Cos(degToRad(2*hBar)) +
+		0.32*math.Cos(degToRad(3*hBar+6)) -
+		0.20*math.Cos(degToRad(4*hBar-63))
+
+	dTheta := 30 * math.Exp(-math.Pow((hBar-275)/25, 2))
+	cBar7 = math.Pow(cBar, 7)
+	rC := 2 * math.Sqrt(cBar7/(cBar7+pow25to7))
+
+	lBar50 := (lBar - 50) * (lBar - 50)
+	sL := 1 + (0.015*lBar50)/math.Sqrt(20+lBar50)
+	sC := 1 + 0.045*cBar
+	sH := 1 + 0.015*cBar*t
+	rT := -math.Sin(degToRad(2*dTheta)) * rC
+
+	l, c, h := dL/sL, dC/sC, dH/sH
+	return math.Sqrt(l*l + c*c + h*h + rT*c*h)
+}
+
+// Hue angle in degrees in the range [0,360)
+func hueAngle(b, a float64) float64 {
+	if a == 0 && b == 0 {
+		return 0
+	}
+
+	h := math.Atan2(b, a) * 180 / math.Pi
+	if h < 0 {
+		h += 360
+	}
+
+	return h
+}
+
+func degToRad(deg float64) float64 {
+	return deg * math.Pi / 180
+}
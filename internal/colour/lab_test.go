@@ -0,0 +1,71 @@
+package colour
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRGB_ToOKLab(t *testing.T) {
+	testCases := []struct {
+		rgb      RGB
+		expected Lab
+	}{
+		{RGB{}, Lab{}},
+		{RGB{R: 65535, G: 65535, B: 65535}, Lab{L: 1}},
+		{RGB{R: 65535}, Lab{L: 0.627955, A: 0.224863, B: 0.125846}},
+	}
+
+	for _, testCase := range testCases {
+		if result := testCase.rgb.ToOKLab(); !isLabEqual(result, testCase.expected, 0.0001) {
+			t.Errorf("OKLab for %v expected %v, got %v", testCase.rgb, testCase.expected, result)
+		}
+	}
+}
+
+func TestRGB_ToCIELab(t *testing.T) {
+	testCases := []struct {
+		rgb      RGB
+		expected Lab
+	}{
+		{RGB{}, Lab{}},
+		{RGB{R: 65535, G: 65535, B: 65535}, Lab{L: 100}},
+		{RGB{R: 65535}, Lab{L: 53.2408, A: 80.0925, B: 67.2032}},
+	}
+
+	for _, testCase := range testCases {
+		if result := testCase.rgb.ToCIELab(); !isLabEqual(result, testCase.expected, 0.01) {
+			t.Errorf("CIELAB for %v expected %v, got %v", testCase.rgb, testCase.expected, result)
+		}
+	}
+}
+
+func TestLab_DeltaE2000(t *testing.T) {
+	// Test data from Sharma, Wu and Dalal (2005)
+	testCases := []struct {
+		a, b     Lab
+		expected float64
+	}{
+		{Lab{50, 2.6772, -79.7751}, Lab{50, 0, -82.7485}, 2.0425},
+		{Lab{50, 3.1571, -77.2803}, Lab{50, 0, -82.7485}, 2.8615},
+		{Lab{50, 2.5, 0}, Lab{73, 25, -18}, 27.1492},
+		{Lab{50, 2.5, 0}, Lab{50, 0, -2.5}, 4.3065},
+		{Lab{60.2574, -34.0099, 36.2677}, Lab{60.4626, -34.1751, 39.4387}, 1.2644},
+		{Lab{22.7233, 20.0904, -46.694}, Lab{23.0331, 14.973, -42.5619}, 2.0373},
+		{Lab{50, 0, 0}, Lab{50, 0, 0}, 0},
+	}
+
+	for _, testCase := range testCases {
+		result := testCase.a.DeltaE2000(testCase.b)
+		if math.Abs(result-testCase.expected) > 0.0001 {
+			t.Errorf("CIEDE2000 between %v and %v expected %f, got %f", testCase.a, testCase.b, testCase.expected, result)
+		}
+
+		if reverse := testCase.b.DeltaE2000(testCase.a); math.Abs(reverse-result) > 0.000001 {
+			t.Errorf("CIEDE2000 between %v and %v was not symmetric: %f, %f", testCase.a, testCase.b, result, reverse)
+		}
+	}
+}
+
+func isLabEqual(a, b Lab, tolerance float64) bool {
+	return math.Abs(a.L-b.L) < tolerance && math.Abs(a.A-b.A) < tolerance && math.Abs(a.B-b.B) < tolerance
+}
@@ -14,24 +14,50 @@ type PaletteEntry struct {
 }
 
 type PaletteRange struct {
-	Start                    byte `json:"start"`
-	End                      byte `json:"end"`
-	IsPrimaryCompanyColour   bool `json:"is_primary_company_colour"`
-	IsSecondaryCompanyColour bool `json:"is_secondary_company_colour"`
-	IsAnimatedLight          bool `json:"is_animated_light"`
-	IsProcessColour          bool `json:"is_process_colour"`
-	Smoothness               int  `json:"smoothness"`
-	IsNonRenderable          bool `json:"non_renderable"`
-	MaxGapInRegion           int  `json:"max_gap_in_region"`
-	ExpectedColourRange      byte `json:"expected_colour_range"`
+	Start                    byte    `json:"start"`
+	End                      byte    `json:"end"`
+	IsPrimaryCompanyColour   bool    `json:"is_primary_company_colour"`
+	IsSecondaryCompanyColour bool    `json:"is_secondary_company_colour"`
+	IsAnimatedLight          bool    `json:"is_animated_light"`
+	IsProcessColour          bool    `json:"is_process_colour"`
+	Smoothness               int     `json:"smoothness"`
+	IsNonRenderable          bool    `json:"non_renderable"`
+	MaxGapInRegion           int     `json:"max_gap_in_region"`
+	ExpectedColourRange      byte    `json:"expected_colour_range"`
+	Emissive                 float64 `json:"emissive"`
+	IsTransparent            bool    `json:"transparent"`
+	Opacity                  float64 `json:"opacity"`
+	AnimationSpeed           int     `json:"animation_speed"`
+	Material
 }
 
+// Material describes how shiny a colour is. Colours with no specular
+// strength are purely diffuse.
+type Material struct {
+	Specular  float64 `json:"specular"`
+	Shininess float64 `json:"shininess"`
+	Metalness float64 `json:"metalness"`
+}
+
+// MaterialOverride replaces the material of its palette range for a single
+// palette index.
+type MaterialOverride struct {
+	Index byte `json:"index"`
+	Material
+}
+
+const defaultShininess = 16.0
+const defaultOpacity = 0.5
+
 type Palette struct {
-	Entries                           []PaletteEntry `json:"entries"`
-	Ranges                            []PaletteRange `json:"ranges"`
-	CompanyColourLightingContribution float64        `json:"company_colour_lighting_contribution"`
-	DefaultBrightness                 float64        `json:"default_brightness"`
-	CompanyColourLightingScale        float64        `json:"company_colour_lighting_scale"`
+	Entries                           []PaletteEntry     `json:"entries"`
+	Ranges                            []PaletteRange     `json:"ranges"`
+	CompanyColourLightingContribution float64            `json:"company_colour_lighting_contribution"`
+	DefaultBrightness                 float64            `json:"default_brightness"`
+	CompanyColourLightingScale        float64            `json:"company_colour_lighting_scale"`
+	MaterialOverrides                 []MaterialOverride `json:"material_overrides"`
+	CompanyColours                    []CompanyColour    `json:"company_colours"`
+	AnimationFrameDelay               int                `json:"animation_frame_delay"`
 }
 
 func (pe *PaletteEntry) GetRGB() (output RGB) {
@@ -67,6 +93,23 @@ func (p Palette) GetRegularPalette() (pal []RGB) {
 	return
 }
 
+// Get the palette of colours voxels can be remapped to, which excludes
+// special colours, process colours and colours without a range. Index 254
+// is also excluded, as it would be stored as an empty voxel.
+func (p Palette) GetRemapPalette() (pal []RGB) {
+	pal = make([]RGB, len(p.Entries))
+
+	for i, e := range p.Entries {
+		if e.Range != nil && i != 0 && i != 254 && !p.IsSpecialColour(byte(i)) && !e.Range.IsProcessColour {
+			pal[i] = FromPaletteEntry(e)
+		} else {
+			pal[i] = RGB{R: 65535, G: 0, B: 65535}
+		}
+	}
+
+	return
+}
+
 // Get the palette of primary company colours
 func (p Palette) GetPrimaryCompanyColourPalette() (pal []RGB) {
 	pal = make([]RGB, len(p.Entries))
@@ -120,6 +163,58 @@ func (p Palette) GetSmoothness(index byte) (smoothness int) {
 	return
 }
 
+// Get how strongly a colour emits its own light, from 0 (not emissive)
+// to 1 (fully emissive)
+func (p Palette) GetEmissive(index byte) (emissive float64) {
+	if int(index) < len(p.Entries) && p.Entries[index].Range != nil {
+		emissive = Clamp(p.Entries[index].Range.Emissive, 0, 1)
+	}
+
+	return
+}
+
+func (p Palette) IsTransparent(index byte) bool {
+	if int(index) < len(p.Entries) && p.Entries[index].Range != nil {
+		return p.Entries[index].Range.IsTransparent
+	}
+
+	return false
+}
+
+// Get how much light a colour blocks, from 0 (fully transparent) to 1 (fully
+// opaque). Only transparent ranges are ever less than fully opaque.
+func (p Palette) GetOpacity(index byte) float64 {
+	if !p.IsTransparent(index) {
+		return 1.0
+	}
+
+	opacity := p.Entries[index].Range.Opacity
+	if opacity <= 0 {
+		return defaultOpacity
+	}
+
+	return Clamp(opacity, 0, 1)
+}
+
+// Get the material for a colour, taking any per-index overrides into account
+func (p Palette) GetMaterial(index byte) (material Material) {
+	if int(index) < len(p.Entries) && p.Entries[index].Range != nil {
+		material = p.Entries[index].Range.Material
+	}
+
+	for _, o := range p.MaterialOverrides {
+		if o.Index == index {
+			material = o.Material
+		}
+	}
+
+	if material.Shininess <= 0 {
+		material.Shininess = defaultShininess
+	}
+
+	return
+}
+
 func (p Palette) GetMaskColour(index byte) (msk byte) {
 	if int(index) < len(p.Entries) {
 		entry := p.Entries[index]
@@ -283,6 +378,9 @@ func (p *Palette) SetRanges(ranges []PaletteRange) (err error) {
 	}
 
 	for i, r := range ranges {
+		if r.Start > r.End || int(r.End) >= len(p.Entries) {
+			return fmt.Errorf("range %d (%d-%d) is outside the %d palette colours", i, r.Start, r.End, len(p.Entries))
+		}
 
 		// Set the default for max region gap
 		if r.MaxGapInRegion == 0 {
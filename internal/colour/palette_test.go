@@ -96,6 +96,37 @@ func TestPalette_GetMaskColour(t *testing.T) {
 
 }
 
+func TestPalette_GetEmissive(t *testing.T) {
+	palette, _ := FromJson(strings.NewReader(exampleJson))
+	palette.SetRanges([]PaletteRange{{Start: 1, End: 1, Emissive: 0.5}, {Start: 2, End: 2, Emissive: 2.0}})
+
+	expected := []float64{0, 0.5, 1.0}
+
+	for i, e := range expected {
+		if emissive := palette.GetEmissive(byte(i)); emissive != e {
+			t.Errorf("entry at %d not returned correctly: was %f, expected %f", i, emissive, e)
+		}
+	}
+}
+
+func TestPalette_GetMaterial(t *testing.T) {
+	palette, _ := FromJson(strings.NewReader(exampleJson))
+	palette.SetRanges([]PaletteRange{{Start: 1, End: 2, Material: Material{Specular: 0.5, Shininess: 32}}})
+	palette.MaterialOverrides = []MaterialOverride{{Index: 2, Material: Material{Specular: 1.0, Metalness: 1.0}}}
+
+	expected := []Material{
+		{Shininess: defaultShininess},
+		{Specular: 0.5, Shininess: 32},
+		{Specular: 1.0, Shininess: defaultShininess, Metalness: 1.0},
+	}
+
+	for i, e := range expected {
+		if material := palette.GetMaterial(byte(i)); material != e {
+			t.Errorf("entry at %d not returned correctly: was %v, expected %v", i, material, e)
+		}
+	}
+}
+
 func TestPalette_GetFromReader_DetectsDuplicateRanges(t *testing.T) {
 	const json = "{\"entries\": [[0,0,0],[255,255,255],[255,127,0]], \"ranges\": [{\"start\": 0, \"end\": 1},{\"start\": 1, \"end\": 2}]}"
 	_, err := FromJson(strings.NewReader(json))
@@ -144,3 +175,41 @@ func TestPalette_GetLitIndexed(t *testing.T) {
 	}
 
 }
+
+func TestPalette_GetCompanyColourRemap(t *testing.T) {
+	p := Palette{
+		Entries:        make([]PaletteEntry, 10),
+		CompanyColours: []CompanyColour{{Name: "Dark_Blue", Start: 2}, {Name: "red", Start: 6}},
+	}
+	p.SetRanges([]PaletteRange{
+		{Start: 2, End: 3, IsPrimaryCompanyColour: true},
+		{Start: 4, End: 5, IsSecondaryCompanyColour: true},
+		{Start: 6, End: 9},
+	})
+
+	pairs, err := p.GetCompanyColourPairs([]string{"dark blue", "RED"})
+	if err != nil {
+		t.Fatalf("error getting company colours: %v", err)
+	}
+
+	if len(pairs) != 1 || pairs[0][0].Start != 2 || pairs[0][1].Start != 6 {
+		t.Fatalf("unexpected company colour pairs %v", pairs)
+	}
+
+	expected := []byte{0, 1, 2, 3, 6, 7, 6, 7, 8, 9}
+	remap := p.GetCompanyColourRemap(pairs[0][0], pairs[0][1])
+
+	for i, e := range expected {
+		if remap[i] != e {
+			t.Errorf("index %d expected to map to %d, got %d", i, e, remap[i])
+		}
+	}
+
+	if pairs, err := p.GetCompanyColourPairs(nil); err != nil || len(pairs) != 2 {
+		t.Errorf("expected a pair for every company colour, got %v (%v)", pairs, err)
+	}
+
+	if _, err := p.GetCompanyColourPairs([]string{"green"}); err == nil {
+		t.Errorf("expected error for unknown company colour")
+	}
+}
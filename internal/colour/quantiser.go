@@ -0,0 +1,118 @@
+package colour
+
+import "math"
+
+// Colour distance metrics used to choose the closest palette colour
+const (
+	DistanceRGB       = "rgb"
+	DistanceOKLab     = "oklab"
+	DistanceCIEDE2000 = "ciede2000"
+)
+
+// Quantiser finds the closest colour in a palette. Perceptual values for the
+// palette are calculated once when the quantiser is created.
+type Quantiser struct {
+	Palette  []RGB
+	distance string
+	lab      []Lab
+}
+
+// NewQuantiser returns a quantiser for the palette using the named distance
+// metric. Unrecognised metrics use RGB distance.
+func NewQuantiser(palette []RGB, distance string) (q Quantiser) {
+	q.Palette = palette
+	q.distance = DistanceRGB
+
+	if distance == DistanceOKLab || distance == DistanceCIEDE2000 {
+		q.distance = distance
+		q.lab = make([]Lab, len(palette))
+		for i, p := range palette {
+			q.lab[i] = q.toLab(p)
+		}
+	}
+
+	return
+}
+
+func (q Quantiser) toLab(c RGB) Lab {
+	if q.distance == DistanceCIEDE2000 {
+		return c.ToCIELab()
+	}
+
+	return c.ToOKLab()
+}
+
+// IsExcluded is true for palette entries which have been swapped for magenta
+// (or white) placeholders, which are not valid choices
+func (q Quantiser) IsExcluded(index int) bool {
+	p := q.Palette[index]
+	return p.R > 65000 && (p.G == 0 || p.G > 65000) && p.B > 65000
+}
+
+// GetBestIndex returns the index of the palette colour closest to c
+func (q Quantiser) GetBestIndex(c RGB) byte {
+	if q.distance == DistanceRGB {
+		return q.getBestIndexRGB(c)
+	}
+
+	lab := q.toLab(c)
+	bestIndex, bestDistance := 0, math.MaxFloat64
+
+	for index := range q.Palette {
+		if q.IsExcluded(index) {
+			continue
+		}
+
+		distance := q.labDistance(lab, q.lab[index])
+		if distance < bestDistance {
+			bestIndex, bestDistance = index, distance
+			if distance == 0 {
+				break
+			}
+		}
+	}
+
+	return byte(bestIndex)
+}
+
+func (q Quantiser) getBestIndexRGB(c RGB) byte {
+	bestIndex, bestSum := 0, math.MaxFloat64
+	for index, p := range q.Palette {
+		if q.IsExcluded(index) {
+			continue
+		}
+
+		sum := squareDiff(c.R, p.R) + squareDiff(c.G, p.G) + squareDiff(c.B, p.B)
+		if sum < bestSum {
+			bestIndex, bestSum = index, sum
+			if sum == 0 {
+				break
+			}
+		}
+	}
+
+	return byte(bestIndex)
+}
+
+// GetError returns the perceptual difference between c and a palette colour,
+// measured with OKLab unless the quantiser uses CIEDE2000
+func (q Quantiser) GetError(c RGB, index byte) float64 {
+	if q.distance == DistanceCIEDE2000 {
+		return c.ToCIELab().DeltaE2000(q.Palette[index].ToCIELab())
+	}
+
+	return c.ToOKLab().Distance(q.Palette[index].ToOKLab())
+}
+
+func (q Quantiser) labDistance(a, b Lab) float64 {
+	if q.distance == DistanceCIEDE2000 {
+		return a.DeltaE2000(b)
+	}
+
+	return a.Distance(b)
+}
+
+func squareDiff(a, b float64) float64 {
+	diff := a - b
+	return diff * diff
+}
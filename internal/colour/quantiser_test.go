@@ -0,0 +1,42 @@
+package colour
+
+import "testing"
+
+func TestQuantiser_GetBestIndex(t *testing.T) {
+	entries := []PaletteEntry{{R: 255, G: 255, B: 255}, {R: 146, G: 177, B: 50}, {R: 11, G: 7, B: 33}}
+	palette := make([]RGB, len(entries))
+	for i, e := range entries {
+		palette[i] = FromPaletteEntry(e)
+	}
+
+	// Placeholder for an unusable colour
+	palette[0] = RGB{R: 65535, G: 0, B: 65535}
+
+	// A dark green is closest to near-black in RGB, but looks closer to the green
+	darkGreen := FromPaletteEntry(PaletteEntry{R: 39, G: 96, B: 10})
+
+	testCases := []struct {
+		distance string
+		expected byte
+	}{
+		{DistanceRGB, 2},
+		{"", 2},
+		{DistanceOKLab, 1},
+		{DistanceCIEDE2000, 1},
+	}
+
+	for _, testCase := range testCases {
+		q := NewQuantiser(palette, testCase.distance)
+		if result := q.GetBestIndex(darkGreen); result != testCase.expected {
+			t.Errorf("Distance %s: expected index %d, got %d", testCase.distance, testCase.expected, result)
+		}
+
+		if result := q.GetBestIndex(palette[0]); result == 0 {
+			t.Errorf("Distance %s: chose placeholder colour", testCase.distance)
+		}
+
+		if result := q.GetBestIndex(palette[2]); result != 2 {
+			t.Errorf("Distance %s: exact match expected index 2, got %d", testCase.distance, result)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package colour
+
+import "math"
+
+// Limits used when dividing a palette into ranges
+const (
+	maxDerivedRangeLength   = 16
+	maxDerivedHueDifference = 30.0
+	minDerivedSaturation    = 0.1
+	derivedLightnessMargin  = 0.01
+)
+
+// DeriveRanges divides a palette into ranges of colours which get steadily
+// lighter without changing hue, as is typical for palettes intended for
+// shading. Index 0 is treated as transparent and is never part of a range.
+func DeriveRanges(entries []PaletteEntry) (ranges []PaletteRange) {
+	start := 1
+
+	for i := 2; i <= len(entries); i++ {
+		if i == len(entries) || i-start >= maxDerivedRangeLength || !isNextShade(entries[i-1], entries[i]) {
+			ranges = append(ranges, PaletteRange{Start: byte(start), End: byte(i - 1)})
+			start = i
+		}
+	}
+
+	return
+}
+
+// Whether b is a lighter shade of the same hue as a
+func isNextShade(a, b PaletteEntry) bool {
+	la, lb := FromPaletteEntry(a).ToOKLab(), FromPaletteEntry(b).ToOKLab()
+
+	if lb.L < la.L-derivedLightnessMargin {
+		return false
+	}
+
+	greyA, greyB := isGrey(la), isGrey(lb)
+	if greyA || greyB {
+		return greyA == greyB
+	}
+
+	difference := math.Abs(hueAngle(la.B, la.A) - hueAngle(lb.B, lb.A))
+	if difference > 180 {
+		difference = 360 - difference
+	}
+
+	return difference <= maxDerivedHueDifference
+}
+
+// Colours with very little saturation for their lightness
+func isGrey(lab Lab) bool {
+	return lab.L == 0 || math.Hypot(lab.A, lab.B)/lab.L < minDerivedSaturation
+}
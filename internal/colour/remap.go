@@ -0,0 +1,91 @@
+package colour
+
+// GetIndexMap returns the closest index in the target palette for each index in
+// p. Special colours (company colours, animated lights and non-renderable colours)
+// map to the same position in the equivalent range of the target palette. Other
+// colours map to the closest regular colour using the named distance metric.
+func (p Palette) GetIndexMap(target Palette, distance string) (indexMap [256]byte) {
+	return p.getIndexMap(target, NewQuantiser(target.GetRegularPalette(), distance))
+}
+
+// Remap describes how to recolour an object from one palette to another
+type Remap struct {
+	IndexMap [256]byte
+
+	// The CIEDE2000 difference between each colour and its replacement
+	Difference [256]float64
+}
+
+// GetRemap returns the closest colour in the target palette for each index in p.
+// Unlike GetIndexMap, regular colours are only mapped to colours which can be
+// rendered as voxels, so never to special or process colours.
+func (p Palette) GetRemap(target Palette, distance string) (r Remap) {
+	r.IndexMap = p.getIndexMap(target, NewQuantiser(target.GetRemapPalette(), distance))
+
+	for i := 1; i < len(p.Entries) && i < len(r.IndexMap); i++ {
+		if int(r.IndexMap[i]) < len(target.Entries) {
+			r.Difference[i] = FromPaletteEntry(p.Entries[i]).ToCIELab().DeltaE2000(FromPaletteEntry(target.Entries[r.IndexMap[i]]).ToCIELab())
+		}
+	}
+
+	return
+}
+
+func (p Palette) getIndexMap(target Palette, q Quantiser) (indexMap [256]byte) {
+	for i := 1; i < len(p.Entries) && i < len(indexMap); i++ {
+		if idx, ok := p.getSpecialIndex(target, byte(i)); ok {
+			indexMap[i] = idx
+			continue
+		}
+
+		indexMap[i] = q.GetBestIndex(FromPaletteEntry(p.Entries[i]))
+	}
+
+	return
+}
+
+func (p Palette) getSpecialIndex(target Palette, index byte) (byte, bool) {
+	rng := p.Entries[index].Range
+	if rng == nil || !p.IsSpecialColour(index) {
+		return 0, false
+	}
+
+	// Match ranges of the same kind in order, so the second animated range in
+	// this palette maps to the second animated range in the target
+	n := 0
+	for i := range p.Ranges {
+		if &p.Ranges[i] == rng {
+			break
+		}
+		if isSameKind(p.Ranges[i], *rng) {
+			n++
+		}
+	}
+
+	for _, r := range target.Ranges {
+		if !isSameKind(r, *rng) {
+			continue
+		}
+
+		if n > 0 {
+			n--
+			continue
+		}
+
+		offset := int(index) - int(rng.Start)
+		if offset > int(r.End)-int(r.Start) {
+			offset = int(r.End) - int(r.Start)
+		}
+
+		return r.Start + byte(offset), true
+	}
+
+	return 0, false
+}
+
+func isSameKind(a, b PaletteRange) bool {
+	return a.IsPrimaryCompanyColour == b.IsPrimaryCompanyColour &&
+		a.IsSecondaryCompanyColour == b.IsSecondaryCompanyColour &&
+		a.IsAnimatedLight == b.IsAnimatedLight &&
+		a.IsNonRenderable == b.IsNonRenderable
+}
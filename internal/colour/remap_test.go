@@ -0,0 +1,73 @@
+package colour
+
+import "testing"
+
+func TestPalette_GetIndexMap(t *testing.T) {
+	source := Palette{Entries: []PaletteEntry{
+		{}, {R: 250, G: 10, B: 10}, {R: 10, G: 10, B: 250},
+		{R: 0, G: 0, B: 100}, {R: 0, G: 0, B: 150}, {R: 0, G: 0, B: 200},
+		{R: 200, G: 200, B: 0}, {R: 250, G: 250, B: 0},
+	}}
+	source.SetRanges([]PaletteRange{
+		{Start: 1, End: 2},
+		{Start: 3, End: 5, IsPrimaryCompanyColour: true},
+		{Start: 6, End: 6, IsAnimatedLight: true},
+		{Start: 7, End: 7, IsAnimatedLight: true},
+	})
+
+	target := Palette{Entries: []PaletteEntry{
+		{}, {R: 0, G: 0, B: 255}, {R: 255, G: 0, B: 0},
+		{R: 0, G: 0, B: 90}, {R: 0, G: 0, B: 180},
+		{R: 255, G: 255, B: 0}, {R: 255, G: 255, B: 0},
+	}}
+	target.SetRanges([]PaletteRange{
+		{Start: 1, End: 2},
+		{Start: 3, End: 4, IsPrimaryCompanyColour: true},
+		{Start: 5, End: 5, IsAnimatedLight: true},
+		{Start: 6, End: 6, IsAnimatedLight: true},
+	})
+
+	// Regular colours go to the closest colour, company colours to the same
+	// position in the company colour range and animated colours to the
+	// matching animated range
+	expected := []byte{0, 2, 1, 3, 4, 4, 5, 6}
+	result := source.GetIndexMap(target, DistanceRGB)
+
+	for i, e := range expected {
+		if result[i] != e {
+			t.Errorf("Index %d expected to map to %d, got %d", i, e, result[i])
+		}
+	}
+}
+
+func TestPalette_GetRemap(t *testing.T) {
+	source := Palette{Entries: []PaletteEntry{
+		{}, {R: 250, G: 10, B: 10}, {R: 10, G: 10, B: 250}, {R: 255, G: 255, B: 0},
+	}}
+	source.SetRanges([]PaletteRange{{Start: 1, End: 3}})
+
+	target := Palette{Entries: []PaletteEntry{
+		{}, {R: 0, G: 0, B: 255}, {R: 255, G: 0, B: 0},
+		{R: 255, G: 255, B: 0}, {R: 200, G: 200, B: 0},
+	}}
+	target.SetRanges([]PaletteRange{
+		{Start: 1, End: 2},
+		{Start: 3, End: 3, IsAnimatedLight: true},
+		{Start: 4, End: 4},
+	})
+
+	// Regular colours are never remapped to special colours, even when
+	// they are an exact match
+	expected := []byte{0, 2, 1, 4}
+	result := source.GetRemap(target, DistanceRGB)
+
+	for i, e := range expected {
+		if result.IndexMap[i] != e {
+			t.Errorf("Index %d expected to map to %d, got %d", i, e, result.IndexMap[i])
+		}
+	}
+
+	if result.Difference[1] <= 0 || result.Difference[1] > result.Difference[3] {
+		t.Errorf("Unexpected remap differences %v", result.Difference[:4])
+	}
+}
@@ -77,6 +77,14 @@ func (rgb RGB) MultiplyBy(value float64) (result RGB) {
 	return
 }
 
+func (rgb RGB) MultiplyByRGB(input RGB) (result RGB) {
+	result.R = rgb.R * input.R
+	result.G = rgb.G * input.G
+	result.B = rgb.B * input.B
+
+	return
+}
+
 func FromPaletteEntry(p PaletteEntry) RGB {
 	return RGB{
 		R: float64(p.R) * 255,
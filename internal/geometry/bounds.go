@@ -0,0 +1,28 @@
+package geometry
+
+// Bounds is an axis-aligned box of voxels, including both Min and Max
+type Bounds struct {
+	Min, Max Point
+}
+
+func (b Bounds) Contains(x, y, z int) bool {
+	return x >= b.Min.X && x <= b.Max.X && y >= b.Min.Y && y <= b.Max.Y && z >= b.Min.Z && z <= b.Max.Z
+}
+
+// Centre returns the centre of the bounds, treating each voxel as the space
+// from i to i+1
+func (b Bounds) Centre() Vector3 {
+	return Vector3{
+		X: float64(b.Min.X+b.Max.X+1) / 2,
+		Y: float64(b.Min.Y+b.Max.Y+1) / 2,
+		Z: float64(b.Min.Z+b.Max.Z+1) / 2,
+	}
+}
+
+// Intersect returns the part of the bounds which is also inside c
+func (b Bounds) Intersect(c Bounds) Bounds {
+	return Bounds{
+		Min: Point{X: max(b.Min.X, c.Min.X), Y: max(b.Min.Y, c.Min.Y), Z: max(b.Min.Z, c.Min.Z)},
+		Max: Point{X: min(b.Max.X, c.Max.X), Y: min(b.Max.Y, c.Max.Y), Z: min(b.Max.Z, c.Max.Z)},
+	}
+}
@@ -0,0 +1,42 @@
+package geometry
+
+import "testing"
+
+func TestBounds_Contains(t *testing.T) {
+	b := Bounds{Min: Point{X: 1, Y: 2, Z: 3}, Max: Point{X: 4, Y: 5, Z: 6}}
+
+	testCases := []struct {
+		x, y, z  int
+		expected bool
+	}{
+		{1, 2, 3, true},
+		{4, 5, 6, true},
+		{2, 3, 4, true},
+		{0, 3, 4, false},
+		{2, 6, 4, false},
+		{2, 3, 7, false},
+	}
+
+	for _, testCase := range testCases {
+		if result := b.Contains(testCase.x, testCase.y, testCase.z); result != testCase.expected {
+			t.Errorf("%v contains %d,%d,%d expected %v, was %v", b, testCase.x, testCase.y, testCase.z, testCase.expected, result)
+		}
+	}
+}
+
+func TestBounds_Centre(t *testing.T) {
+	b := Bounds{Min: Point{X: 2, Y: 0, Z: 1}, Max: Point{X: 5, Y: 0, Z: 2}}
+	if centre := b.Centre(); centre != (Vector3{X: 4, Y: 0.5, Z: 2}) {
+		t.Errorf("expected centre {4 0.5 2}, got %v", centre)
+	}
+}
+
+func TestBounds_Intersect(t *testing.T) {
+	a := Bounds{Min: Point{X: 0, Y: 0, Z: 0}, Max: Point{X: 10, Y: 10, Z: 10}}
+	b := Bounds{Min: Point{X: 5, Y: -2, Z: 3}, Max: Point{X: 12, Y: 8, Z: 10}}
+	expected := Bounds{Min: Point{X: 5, Y: 0, Z: 3}, Max: Point{X: 10, Y: 8, Z: 10}}
+
+	if result := a.Intersect(b); result != expected {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+}
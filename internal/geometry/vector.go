@@ -86,6 +86,18 @@ func (a Vector3) Cross(b Vector3) Vector3 {
 	}
 }
 
+// Get two unit vectors perpendicular to this one and to each other
+func (a Vector3) Tangents() (tangent Vector3, bitangent Vector3) {
+	up := UnitZ()
+	if math.Abs(a.Z) > 0.9 {
+		up = UnitX()
+	}
+
+	tangent = up.Cross(a).Normalise()
+	bitangent = a.Cross(tangent)
+	return
+}
+
 func (a Vector3) Dot(b Vector3) float64 {
 	return (a.X * b.X) + (a.Y * b.Y) + (a.Z * b.Z)
 }
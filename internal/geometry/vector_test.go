@@ -115,3 +115,18 @@ func TestVector2_Dot(t *testing.T) {
 func TestVector2_DivideByVector(t *testing.T) {
 	testVector2(Vector2{0.5, 0}, Vector2{1.0, 0.0}.DivideByVector(Vector2{2.0, 1.0}), t)
 }
+
+func TestVector3_Tangents(t *testing.T) {
+	for _, v := range []Vector3{UnitX(), UnitZ(), {X: 1, Y: 2, Z: -3}} {
+		v = v.Normalise()
+		tangent, bitangent := v.Tangents()
+
+		if math.Abs(tangent.Dot(v)) > 1e-9 || math.Abs(bitangent.Dot(v)) > 1e-9 || math.Abs(tangent.Dot(bitangent)) > 1e-9 {
+			t.Errorf("Tangents %v, %v of %v are not perpendicular", tangent, bitangent, v)
+		}
+
+		if math.Abs(tangent.Length()-1) > 1e-9 || math.Abs(bitangent.Length()-1) > 1e-9 {
+			t.Errorf("Tangents %v, %v of %v are not unit length", tangent, bitangent, v)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package manifest
+
+import (
+	"fmt"
+	"github.com/mattkimber/gorender/internal/geometry"
+	"math"
+)
+
+// Camera presets for common games and views
+const (
+	CameraIsometric = "isometric"
+	CameraDimetric  = "dimetric"
+	CameraRCT       = "rct"
+	CameraTopDown   = "top_down"
+	CameraSideOn    = "side_on"
+)
+
+// Camera projections
+const (
+	ProjectionOrthographic = "orthographic"
+	ProjectionPerspective  = "perspective"
+)
+
+const defaultFieldOfView = 45
+
+type cameraPreset struct {
+	yaw, pitch float64
+}
+
+var cameraPresets = map[string]cameraPreset{
+	// True isometric, where the axes are 120 degrees apart on screen
+	CameraIsometric: {pitch: math.Asin(math.Tan(geometry.DegToRad(30))) * 180 / math.Pi},
+	// Pixel art dimetric, where lines along the ground go 2 pixels across for
+	// every pixel up
+	CameraDimetric: {pitch: 30},
+	// Dimetric with the object's axes along the diagonals of the screen, as in
+	// RollerCoaster Tycoon and Locomotion
+	CameraRCT:     {yaw: 45, pitch: 30},
+	CameraTopDown: {pitch: 90},
+	CameraSideOn:  {pitch: 0},
+}
+
+// Camera is a camera which replaces the default view. Angles are in degrees:
+// yaw is added to the angle of each sprite, pitch is the angle looking down at
+// the object and roll rotates the image clockwise. Scale is the number of
+// pixels per voxel, and is worked out from the sprite width if not set.
+//
+// Perspective cameras look from Position towards Target, both in object space,
+// with a vertical field of view of FieldOfView degrees. The position is turned
+// around the target by the angle of each sprite and the yaw. Without a position
+// the camera is placed to fit the whole object in view, looking from the yaw
+// and pitch.
+type Camera struct {
+	Preset      string            `json:"preset"`
+	Projection  string            `json:"projection"`
+	Yaw         *float64          `json:"yaw"`
+	Pitch       *float64          `json:"pitch"`
+	Roll        *float64          `json:"roll"`
+	Scale       float64           `json:"scale"`
+	FieldOfView float64           `json:"fov"`
+	Position    *geometry.Vector3 `json:"position"`
+	Target      *geometry.Vector3 `json:"target"`
+}
+
+// Check the preset and projection are ones which exist
+func (c *Camera) validate() error {
+	if _, ok := cameraPresets[c.Preset]; c.Preset != "" && !ok {
+		return fmt.Errorf("unknown camera preset %q", c.Preset)
+	}
+
+	if c.Projection != "" && c.Projection != ProjectionOrthographic && c.Projection != ProjectionPerspective {
+		return fmt.Errorf("unknown camera projection %q", c.Projection)
+	}
+
+	return nil
+}
+
+func (c *Camera) getPreset() cameraPreset {
+	if preset, ok := cameraPresets[c.Preset]; ok {
+		return preset
+	}
+
+	return cameraPreset{pitch: 30}
+}
+
+// GetYaw returns the yaw of the camera, taken from the preset if not set
+func (c *Camera) GetYaw() float64 {
+	if c.Yaw != nil {
+		return *c.Yaw
+	}
+
+	return c.getPreset().yaw
+}
+
+// GetPitch returns the pitch of the camera, taken from the preset if not set
+func (c *Camera) GetPitch() float64 {
+	if c.Pitch != nil {
+		return *c.Pitch
+	}
+
+	return c.getPreset().pitch
+}
+
+// GetRoll returns the roll of the camera, which is 0 if not set
+func (c *Camera) GetRoll() float64 {
+	if c.Roll != nil {
+		return *c.Roll
+	}
+
+	return 0
+}
+
+// GetFieldOfView returns the vertical field of view of a perspective camera
+func (c *Camera) GetFieldOfView() float64 {
+	if c.FieldOfView > 0 {
+		return c.FieldOfView
+	}
+
+	return defaultFieldOfView
+}
+
+// IsPerspective returns true if rays are cast from a single point rather than
+// in parallel
+func (c *Camera) IsPerspective() bool {
+	return c.Projection == ProjectionPerspective
+}
+
+// GetCameraPosition returns where a perspective camera is and what it is
+// looking at for a sprite. The default target is the given centre of the object.
+func (m *Manifest) GetCameraPosition(spr Sprite, centre geometry.Vector3) (position, target geometry.Vector3) {
+	target = centre
+	if m.Camera.Target != nil {
+		target = *m.Camera.Target
+	}
+
+	direction, _, _ := m.GetCameraAxes(spr)
+	if m.Camera.Position == nil {
+		// Far enough away for a sphere around the object to fit in view
+		distance := (m.Size.Length() / 2) / math.Sin(geometry.DegToRad(m.Camera.GetFieldOfView()/2))
+		return target.Add(direction.MultiplyByConstant(distance)), target
+	}
+
+	// Turn the position around the target in the same way as the view direction
+	angle := geometry.DegToRad(m.GetViewAngle(spr))
+	cos, sin := math.Cos(angle), math.Sin(angle)
+	offset := m.Camera.Position.Subtract(target)
+	offset.X, offset.Y = offset.X*cos+offset.Y*sin, offset.Y*cos-offset.X*sin
+
+	return target.Add(offset), target
+}
+
+// GetViewAngle returns the angle the object is viewed from for a sprite
+func (m *Manifest) GetViewAngle(spr Sprite) float64 {
+	if m.Camera == nil {
+		return spr.Angle
+	}
+
+	return spr.Angle + m.Camera.GetYaw()
+}
+
+// GetCameraAxes returns the direction from the object towards the camera, and
+// the directions which are right and up on screen
+func (m *Manifest) GetCameraAxes(spr Sprite) (direction, right, up geometry.Vector3) {
+	angle, pitch := geometry.DegToRad(m.GetViewAngle(spr)), geometry.DegToRad(m.Camera.GetPitch())
+	cos, sin := math.Cos(angle), math.Sin(angle)
+
+	direction = geometry.Vector3{X: -cos * math.Cos(pitch), Y: sin * math.Cos(pitch), Z: math.Sin(pitch)}
+	right = geometry.Vector3{X: sin, Y: cos}
+	up = right.Cross(direction)
+
+	roll := geometry.DegToRad(m.Camera.GetRoll())
+	right, up = right.MultiplyByConstant(math.Cos(roll)).Add(up.MultiplyByConstant(math.Sin(roll))),
+		up.MultiplyByConstant(math.Cos(roll)).Subtract(right.MultiplyByConstant(math.Sin(roll)))
+
+	return
+}
+
+// GetCameraScale returns the number of pixels per voxel for a sprite
+func (m *Manifest) GetCameraScale(spr Sprite) float64 {
+	if m.Camera.Scale > 0 {
+		return m.Camera.Scale
+	}
+
+	_, right, _ := m.GetCameraAxes(spr)
+	return float64(spr.Width) / getProjectedLength(m.Size, right)
+}
+
+// Set the size of sprites which are not given one to fit the object
+func (m *Manifest) setCameraSpriteSize(spr *Sprite) {
+	_, right, up := m.GetCameraAxes(*spr)
+
+	if m.Camera.IsPerspective() {
+		if spr.Height == 0 {
+			spr.Height = spr.Width
+		}
+		return
+	}
+
+	if spr.Width == 0 {
+		spr.Width = getPixelLength(getProjectedLength(m.Size, right) * m.Camera.Scale)
+	}
+
+	if spr.Height == 0 {
+		spr.Height = getPixelLength(getProjectedLength(m.Size, up) * m.GetCameraScale(*spr))
+	}
+}
+
+// Get the length of a box of the given size along an axis
+func getProjectedLength(size geometry.Vector3, axis geometry.Vector3) float64 {
+	return math.Abs(size.X*axis.X) + math.Abs(size.Y*axis.Y) + math.Abs(size.Z*axis.Z)
+}
+
+// Get the number of whole pixels needed to cover a length, ignoring rounding
+// errors from the trigonometry so sizes don't change as the camera turns
+func getPixelLength(length float64) int {
+	return int(math.Ceil(length - 0.0001))
+}
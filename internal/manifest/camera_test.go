@@ -0,0 +1,114 @@
+package manifest
+
+import (
+	"github.com/mattkimber/gorender/internal/geometry"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestFromJson_Camera(t *testing.T) {
+	testCases := []struct {
+		json             string
+		yaw, pitch, roll float64
+	}{
+		{`{"preset": "isometric"}`, 0, 35.264, 0},
+		{`{"preset": "dimetric"}`, 0, 30, 0},
+		{`{"preset": "rct"}`, 45, 30, 0},
+		{`{"preset": "top_down"}`, 0, 90, 0},
+		{`{"preset": "side_on", "pitch": 10, "roll": 5}`, 0, 10, 5},
+		{`{"yaw": 15}`, 15, 30, 0},
+	}
+
+	for _, testCase := range testCases {
+		m, err := FromJson(strings.NewReader(`{"camera": ` + testCase.json + `}`))
+		if err != nil {
+			t.Fatalf("Could not process manifest: %v", err)
+		}
+
+		c := m.Camera
+		if math.Abs(c.GetYaw()-testCase.yaw) > 0.001 || math.Abs(c.GetPitch()-testCase.pitch) > 0.001 || math.Abs(c.GetRoll()-testCase.roll) > 0.001 {
+			t.Errorf("%s: expected %v/%v/%v, got %v/%v/%v", testCase.json, testCase.yaw, testCase.pitch, testCase.roll, c.GetYaw(), c.GetPitch(), c.GetRoll())
+		}
+	}
+
+	if _, err := FromJson(strings.NewReader(`{"camera": {"preset": "oblique"}}`)); err == nil {
+		t.Errorf("Expected error for unknown camera preset")
+	}
+
+	if _, err := FromJson(strings.NewReader(`{"camera": {}, "sprites": [{"angle": 0}]}`)); err == nil {
+		t.Errorf("Expected error for sprite with no width or camera scale")
+	}
+}
+
+func TestManifest_GetCameraAxes(t *testing.T) {
+	m, _ := FromJson(strings.NewReader(`{"camera": {"preset": "top_down"}}`))
+
+	direction, right, up := m.GetCameraAxes(Sprite{})
+	if math.Abs(direction.Z-1) > 0.001 || math.Abs(right.Y-1) > 0.001 || math.Abs(up.X-1) > 0.001 {
+		t.Errorf("Expected top down camera looking down with x up the screen, got %v %v %v", direction, right, up)
+	}
+
+	roll := 90.0
+	m.Camera.Roll = &roll
+	if _, right, up := m.GetCameraAxes(Sprite{}); math.Abs(right.X-1) > 0.001 || math.Abs(up.Y+1) > 0.001 {
+		t.Errorf("Expected roll to rotate the axes, got %v %v", right, up)
+	}
+
+	// Cameras made in code rather than read from JSON use the preset
+	m = Manifest{Camera: &Camera{Preset: CameraRCT}}
+	if angle := m.GetViewAngle(Sprite{Angle: 10}); angle != 55 {
+		t.Errorf("Expected view angle to include the preset yaw, got %v", angle)
+	}
+	if direction, _, _ := m.GetCameraAxes(Sprite{}); math.Abs(direction.Z-0.5) > 0.001 {
+		t.Errorf("Expected camera to look down at the preset pitch, got %v", direction)
+	}
+}
+
+func TestManifest_CameraSpriteSize(t *testing.T) {
+	m, err := FromJson(strings.NewReader(`{"camera": {"preset": "side_on", "scale": 2}, "size": {"x": 40, "y": 20, "z": 10},
+		"sprites": [{"angle": 0}, {"angle": 90}, {"angle": 90, "width": 20}]}`))
+	if err != nil {
+		t.Fatalf("Could not process manifest: %v", err)
+	}
+
+	expected := [][2]int{{40, 20}, {80, 20}, {20, 20}}
+	for i, spr := range m.Sprites {
+		if spr.Width != expected[i][0] || spr.Height != expected[i][1] {
+			t.Errorf("Sprite %d expected size %v, got %dx%d", i, expected[i], spr.Width, spr.Height)
+		}
+	}
+}
+
+func TestManifest_GetCameraPosition(t *testing.T) {
+	m, err := FromJson(strings.NewReader(`{"camera": {"projection": "perspective", "fov": 60, "pitch": 0},
+		"size": {"x": 30, "y": 40, "z": 0}, "sprites": [{"angle": 0, "width": 100}]}`))
+	if err != nil {
+		t.Fatalf("Could not process manifest: %v", err)
+	}
+
+	if m.Sprites[0].Height != 100 {
+		t.Errorf("Expected perspective sprite height to default to its width, got %d", m.Sprites[0].Height)
+	}
+
+	centre := geometry.Vector3{X: 15, Y: 20}
+	position, target := m.GetCameraPosition(Sprite{}, centre)
+	if target != centre || !position.Equals(geometry.Vector3{X: -35, Y: 20}) {
+		t.Errorf("Expected camera at -35,20,0 looking at the centre, got %v looking at %v", position, target)
+	}
+
+	m.Camera.Position = &geometry.Vector3{X: 5, Y: 20, Z: 10}
+	if position, _ = m.GetCameraPosition(Sprite{Angle: 90}, centre); !position.Equals(geometry.Vector3{X: 15, Y: 30, Z: 10}) {
+		t.Errorf("Expected camera position to turn with the sprite angle, got %v", position)
+	}
+
+	if _, err := FromJson(strings.NewReader(`{"camera": {"projection": "fisheye"}}`)); err == nil {
+		t.Errorf("Expected error for unknown projection")
+	}
+
+	for _, section := range []string{`"tiles": {"x": 2}`, `"assembly": {"parts": [{"name": "front"}]}`} {
+		if _, err := FromJson(strings.NewReader(`{"camera": {"projection": "perspective"}, ` + section + `}`)); err == nil {
+			t.Errorf("Expected error for %s with a perspective camera", section)
+		}
+	}
+}
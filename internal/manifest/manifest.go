@@ -2,6 +2,7 @@ package manifest
 
 import (
 	"encoding/json"
+	"fmt"
 	"github.com/mattkimber/gorender/internal/colour"
 	"github.com/mattkimber/gorender/internal/geometry"
 	"github.com/mattkimber/gorender/internal/voxelobject"
@@ -10,13 +11,35 @@ import (
 )
 
 type Definition struct {
-	Object   voxelobject.ProcessedVoxelObject
-	Palette  colour.Palette
-	Manifest Manifest
-	Scale    float64
-	Debug    bool
-	Time     bool
-	Only8bpp bool
+	Object        voxelobject.ProcessedVoxelObject
+	Palette       colour.Palette
+	TargetPalette *colour.Palette
+	Manifest      Manifest
+	Scale         float64
+	Debug         bool
+	Time          bool
+	Only8bpp      bool
+
+	// Output a preview of the 32bpp sprites in each company colour, or only
+	// the primary and secondary colours named here if there are any
+	CompanyColourPreview        bool
+	CompanyColourPreviewColours []string
+
+	// Output an animated preview of the palette animation for each sprite
+	// with animated colours
+	AnimationPreview bool
+
+	// Output a preview of the object turning around, with a sprite every
+	// Turntable degrees, scaled up by TurntableScale. The preview is an
+	// animated GIF unless TurntableFrames is set, when each sprite is output
+	// as a separate PNG.
+	Turntable       float64
+	TurntableScale  int
+	TurntableFrames bool
+
+	// The objects to render for each frame of an animation. Object is used
+	// when there are none.
+	Frames []voxelobject.ProcessedVoxelObject
 }
 
 type Sprite struct {
@@ -31,6 +54,128 @@ type Sprite struct {
 	Slice                int     `json:"slice"`
 	RenderElevationAngle int     `json:"render_elevation"`
 	Joggle               float64 `json:"joggle"`
+	Frame                int     `json:"-"`
+	TileX, TileY         int     `json:"-"`
+	// Only voxels inside the box from ClipMin to ClipMax (inclusive) are
+	// rendered, with the sprite framed as if the whole object was present.
+	ClipMin *geometry.Point `json:"clip_min"`
+	ClipMax *geometry.Point `json:"clip_max"`
+}
+
+const (
+	LightDirectional = "directional"
+	LightPoint       = "point"
+	LightSpot        = "spot"
+)
+
+const (
+	DitherFloydSteinberg = "floyd_steinberg"
+	DitherAtkinson       = "atkinson"
+	DitherJarvis         = "jarvis"
+	DitherOrdered        = "ordered"
+	DitherNone           = "none"
+)
+
+const (
+	AnimationSourceModels = "models"
+	AnimationSourceFiles  = "files"
+	AnimationSourceParts  = "parts"
+)
+
+// Animation describes where the frames of an animated object come from. Frames
+// are either the models in the voxel file, numbered voxel files alongside
+// it where frame n is read from <name>_<n>.vox, or copies of the object with
+// its parts rotated.
+type Animation struct {
+	Frames int    `json:"frames"`
+	Source string `json:"source"`
+	Parts  []Part `json:"parts"`
+}
+
+// Part is a box of voxels which rotates about a pivot over the course of an
+// animation, such as a wheel or coupling rod. Min and Max are inclusive.
+// Parts rotate by Angle degrees (default 360) over all frames, and the pivot
+// is the centre of the box if not set.
+type Part struct {
+	Name  string            `json:"name"`
+	Min   geometry.Point    `json:"min"`
+	Max   geometry.Point    `json:"max"`
+	Pivot *geometry.Vector3 `json:"pivot"`
+	Axis  string            `json:"axis"`
+	Angle float64           `json:"angle"`
+}
+
+// Assembly splits an object into parts which are rendered separately, such as
+// the parts of an articulated vehicle. Each part is a box of the object, or of
+// another voxel file which shares the same origin, and parts keep their
+// positions relative to each other so their sprites line up.
+type Assembly struct {
+	Parts []AssemblyPart `json:"parts"`
+}
+
+// AssemblyPart is one part of an assembly. File is relative to the voxel file
+// being rendered, and the box covers the whole object if Min or Max are not set.
+type AssemblyPart struct {
+	Name string          `json:"name"`
+	File string          `json:"file"`
+	Min  *geometry.Point `json:"min"`
+	Max  *geometry.Point `json:"max"`
+}
+
+// Tiles splits a building covering several tiles into a sprite for each tile
+// and view. X and Y are the number of tiles along each axis of the object,
+// and Size is the length of a tile in voxels.
+type Tiles struct {
+	X    int `json:"x"`
+	Y    int `json:"y"`
+	Size int `json:"size"`
+}
+
+type Light struct {
+	Type          string           `json:"type"`
+	Angle         float64          `json:"angle"`
+	Elevation     float64          `json:"elevation"`
+	Colour        [3]float64       `json:"colour"`
+	Intensity     float64          `json:"intensity"`
+	Shadows       bool             `json:"shadows"`
+	Position      geometry.Vector3 `json:"position"`
+	Direction     geometry.Vector3 `json:"direction"`
+	Radius        float64          `json:"radius"`
+	ConeAngle     float64          `json:"cone_angle"`
+	ConeSoftness  float64          `json:"cone_softness"`
+	AngularRadius float64          `json:"angular_radius"`
+}
+
+type AmbientLight struct {
+	Colour    [3]float64 `json:"colour"`
+	Intensity float64    `json:"intensity"`
+}
+
+var white = [3]float64{255, 255, 255}
+
+// UnmarshalJSON sets defaults for the fields which are left out of the
+// manifest, so that a light can still be turned off or made black
+func (l *Light) UnmarshalJSON(data []byte) error {
+	type light Light
+	result := light{Colour: white, Intensity: 1.0}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return err
+	}
+
+	*l = Light(result)
+	return nil
+}
+
+// UnmarshalJSON sets the ambient light to white if no colour is given
+func (a *AmbientLight) UnmarshalJSON(data []byte) error {
+	type ambientLight AmbientLight
+	result := ambientLight{Colour: white}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return err
+	}
+
+	*a = AmbientLight(result)
+	return nil
 }
 
 type Manifest struct {
@@ -61,10 +206,35 @@ type Manifest struct {
 	RecoveredVoxelSuppression float64          `json:"recovered_voxel_suppression"`
 	Joggle                    float64          `json:"joggle"`
 	DitherFlatAreas           bool             `json:"dither_flat_areas"`
+	Dither                    string           `json:"dither"`
+	DitherStrength            float64          `json:"dither_strength"`
+	DitherSerpentine          bool             `json:"dither_serpentine"`
+	DitherConfineToRange      bool             `json:"dither_confine_to_range"`
+	ColourDistance            string           `json:"colour_distance"`
 	Fosterise                 bool             `json:"fosterise"`
 	NoEdgeFosterisation       bool             `json:"suppress_edge_fosterisation"`
 	SoftShadow                bool             `json:"soft_shadow"`
 	ShadowThreshold           float64          `json:"shadow_threshold"`
+	ShadowHardDistance        int              `json:"shadow_hard_distance"`
+	ShadowFadeDistance        int              `json:"shadow_fade_distance"`
+	ShadowSamples             int              `json:"shadow_samples"`
+	LightingAngularRadius     float64          `json:"lighting_angular_radius"`
+	Lights                    []Light          `json:"lights"`
+	Ambient                   AmbientLight     `json:"ambient"`
+	GlowStrength              float64          `json:"glow_strength"`
+	GlowRadius                float64          `json:"glow_radius"`
+	OcclusionMode             string           `json:"occlusion_mode"`
+	OcclusionRays             int              `json:"occlusion_rays"`
+	OcclusionDistance         float64          `json:"occlusion_distance"`
+	GroundShadow              bool             `json:"ground_shadow"`
+	GroundHeight              float64          `json:"ground_height"`
+	GroundShadowOpacity       float64          `json:"ground_shadow_opacity"`
+	Animation                 *Animation       `json:"animation"`
+	Assembly                  *Assembly        `json:"assembly"`
+	Tiles                     *Tiles           `json:"tiles"`
+	Slopes                    bool             `json:"slopes"`
+	Camera                    *Camera          `json:"camera"`
+	SlopeHeight               int              `json:"slope_height"`
 }
 
 func FromJson(handle io.Reader) (manifest Manifest, err error) {
@@ -72,6 +242,10 @@ func FromJson(handle io.Reader) (manifest Manifest, err error) {
 	manifest.Accuracy = 2
 	manifest.EdgeThreshold = 0.5
 	manifest.TilingMode = "normal"
+	manifest.GroundShadowOpacity = 0.5
+	manifest.ShadowHardDistance = 10
+	manifest.ShadowFadeDistance = 80
+	manifest.DitherStrength = 1.0
 
 	data, err := io.ReadAll(handle)
 
@@ -87,6 +261,37 @@ func FromJson(handle io.Reader) (manifest Manifest, err error) {
 	manifest.Brightness = manifest.Brightness * 65535
 	manifest.Contrast += 1.0
 
+	// Sloped objects are raised by up to two steps, and tile with their
+	// neighbours by continuing the slope at the edges
+	if manifest.Slopes {
+		manifest.SlopeHeight = manifest.GetSlopeHeight()
+		manifest.Size.Z += float64(manifest.SlopeHeight * 2)
+		manifest.TiledNormals = true
+		if manifest.TilingMode == "normal" {
+			manifest.TilingMode = "repeat"
+		}
+	}
+
+	if manifest.Camera != nil {
+		if err = manifest.Camera.validate(); err != nil {
+			return
+		}
+
+		// Tile and part offsets are only worked out for parallel views
+		if manifest.Camera.IsPerspective() && (manifest.Tiles != nil || manifest.Assembly != nil) {
+			return manifest, fmt.Errorf("tiles and assemblies cannot be used with a perspective camera")
+		}
+
+		for _, spr := range manifest.Sprites {
+			if spr.Width == 0 && manifest.Camera.IsPerspective() {
+				return manifest, fmt.Errorf("sprites need a width with a perspective camera")
+			}
+			if spr.Width == 0 && manifest.Camera.Scale == 0 {
+				return manifest, fmt.Errorf("sprites need a width when the camera has no scale")
+			}
+		}
+	}
+
 	// Set up sprite sizes
 	manifest.SetSpriteSizes()
 
@@ -98,6 +303,149 @@ func (m *Manifest) GetFromReader(handle io.Reader) (err error) {
 	return err
 }
 
+// OutputPalette returns the palette used for 8bpp output. This is the palette
+// the object is rendered with, unless a different target palette is set.
+func (d *Definition) OutputPalette() *colour.Palette {
+	if d.TargetPalette != nil {
+		return d.TargetPalette
+	}
+
+	return &d.Palette
+}
+
+// GetObject returns the object to render for a sprite
+func (d *Definition) GetObject(spr Sprite) voxelobject.ProcessedVoxelObject {
+	if spr.Frame < len(d.Frames) {
+		return d.Frames[spr.Frame]
+	}
+
+	return d.Object
+}
+
+// GetFrameSprites returns the sprites to render when there are multiple frames.
+// Every frame of each sprite is rendered before moving on to the next sprite.
+func (d *Definition) GetFrameSprites() []Sprite {
+	if len(d.Frames) < 2 {
+		return d.Manifest.Sprites
+	}
+
+	sprites := make([]Sprite, 0, len(d.Manifest.Sprites)*len(d.Frames))
+	for _, spr := range d.Manifest.Sprites {
+		for f := range d.Frames {
+			spr.Frame = f
+			sprites = append(sprites, spr)
+		}
+	}
+
+	return sprites
+}
+
+// GetSprites returns the sprites to render, with a sprite for every frame
+// of each sprite and then every tile of each frame
+func (d *Definition) GetSprites() []Sprite {
+	sprites := d.GetFrameSprites()
+	if d.Manifest.Tiles == nil {
+		return sprites
+	}
+
+	tiles := d.Manifest.Tiles
+	result := make([]Sprite, 0, len(sprites)*tiles.GetX()*tiles.GetY())
+	for _, spr := range sprites {
+		for x := 0; x < tiles.GetX(); x++ {
+			for y := 0; y < tiles.GetY(); y++ {
+				bounds := d.Manifest.GetTileBounds(x, y)
+				if spr.ClipMin != nil {
+					bounds = bounds.Intersect(geometry.Bounds{Min: *spr.ClipMin, Max: bounds.Max})
+				}
+				if spr.ClipMax != nil {
+					bounds = bounds.Intersect(geometry.Bounds{Min: bounds.Min, Max: *spr.ClipMax})
+				}
+
+				tile := spr
+				tile.TileX, tile.TileY = x, y
+				tile.ClipMin, tile.ClipMax = &bounds.Min, &bounds.Max
+				result = append(result, tile)
+			}
+		}
+	}
+
+	return result
+}
+
+// GetSlopeHeight returns the height in voxels of one step of a slope. If not
+// set this is the height of a step in OpenTTD for a tile the length of the
+// object, at the standard 30 degree render elevation.
+func (m *Manifest) GetSlopeHeight() int {
+	if m.SlopeHeight > 0 {
+		return m.SlopeHeight
+	}
+
+	return int(math.Round(m.Size.X * math.Sqrt2 / 8))
+}
+
+// GetTileBounds returns the voxels which make up a tile, from the ground to
+// the top of the object
+func (m *Manifest) GetTileBounds(x, y int) geometry.Bounds {
+	size := m.Tiles.GetSize(m.Size)
+	return geometry.Bounds{
+		Min: geometry.Point{X: x * size, Y: y * size},
+		Max: geometry.Point{X: (x+1)*size - 1, Y: (y+1)*size - 1, Z: int(m.Size.Z)},
+	}
+}
+
+func (t *Tiles) GetX() int {
+	return max(t.X, 1)
+}
+
+func (t *Tiles) GetY() int {
+	return max(t.Y, 1)
+}
+
+// GetSize returns the length of a tile in voxels. If not set this is the
+// length of the object divided by the number of tiles along it.
+func (t *Tiles) GetSize(objectSize geometry.Vector3) int {
+	if t.Size > 0 {
+		return t.Size
+	}
+
+	return int(objectSize.X) / t.GetX()
+}
+
+// GetSource returns where the frames of the animation come from
+func (a *Animation) GetSource() string {
+	if a.Source == "" && len(a.Parts) > 0 {
+		return AnimationSourceParts
+	} else if a.Source == "" {
+		return AnimationSourceModels
+	}
+
+	return a.Source
+}
+
+// GetBounds returns the box of voxels which makes up the part
+func (p *Part) GetBounds() geometry.Bounds {
+	return geometry.Bounds{Min: p.Min, Max: p.Max}
+}
+
+// GetPivot returns the point the part rotates about
+func (p *Part) GetPivot() geometry.Vector3 {
+	if p.Pivot != nil {
+		return *p.Pivot
+	}
+
+	return p.GetBounds().Centre()
+}
+
+// GetAngle returns the rotation of the part in degrees at a frame
+func (p *Part) GetAngle(frame, frames int) float64 {
+	angle := p.Angle
+	if angle == 0 {
+		angle = 360
+	}
+
+	return angle * float64(frame) / float64(frames)
+}
+
 func (d *Definition) SoftenEdges() bool {
 	return d.Scale >= d.Manifest.SoftenEdges
 }
@@ -105,6 +453,11 @@ func (d *Definition) SoftenEdges() bool {
 func (m *Manifest) SetSpriteSizes() {
 	// Set any auto-height sprites
 	for i := range m.Sprites {
+		if m.Camera != nil {
+			m.setCameraSpriteSize(&m.Sprites[i])
+			continue
+		}
+
 		// 0 means "auto"
 		if m.Sprites[i].Height == 0 {
 			height, delta := getCalculatedSpriteHeight(m, m.Sprites[i])
@@ -118,6 +471,52 @@ func (m *Manifest) SetSpriteSizes() {
 	}
 }
 
+// The distance (in voxels) at which point and spot lights stop having any effect,
+// if not set in the manifest
+const defaultLightRadius = 32
+
+// GetLights returns the lights to render with. If no lights are configured this
+// is a single white shadow-casting light from lighting_angle/lighting_elevation.
+func (m *Manifest) GetLights() (lights []Light) {
+	if len(m.Lights) == 0 {
+		return []Light{{
+			Type:          LightDirectional,
+			Angle:         float64(m.LightingAngle),
+			Elevation:     float64(m.LightingElevation),
+			Colour:        white,
+			Intensity:     1.0,
+			Shadows:       true,
+			AngularRadius: m.LightingAngularRadius,
+		}}
+	}
+
+	lights = make([]Light, len(m.Lights))
+	for i, l := range m.Lights {
+		lights[i] = l
+		if lights[i].Type == "" {
+			lights[i].Type = LightDirectional
+		}
+		if lights[i].Radius == 0 {
+			lights[i].Radius = defaultLightRadius
+		}
+	}
+
+	return
+}
+
+// GetColour returns the light colour as a multiplier in the range [0,1]
+func (l Light) GetColour() colour.RGB {
+	return getLightColour(l.Colour)
+}
+
+func (a AmbientLight) GetColour() colour.RGB {
+	return getLightColour(a.Colour)
+}
+
+func getLightColour(c [3]float64) colour.RGB {
+	return colour.RGB{R: c[0] / 255.0, G: c[1] / 255.0, B: c[2] / 255.0}
+}
+
 func getCalculatedSpriteHeight(m *Manifest, spr Sprite) (height int, delta float64) {
 	size := m.Size
 	cos, sin := math.Cos(geometry.DegToRad(spr.Angle)), math.Sin(geometry.DegToRad(spr.Angle))
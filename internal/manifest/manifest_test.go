@@ -2,20 +2,26 @@ package manifest
 
 import (
 	"github.com/mattkimber/gorender/internal/geometry"
+	"github.com/mattkimber/gorender/internal/voxelobject"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 )
 
 func TestFromJson(t *testing.T) {
 	expected := Manifest{
-		LightingAngle:     60,
-		LightingElevation: 65,
-		DepthInfluence:    0.2,
-		Accuracy:          2,
-		Contrast:          1.0,
-		EdgeThreshold:     0.5,
-		TilingMode:        "normal",
+		LightingAngle:       60,
+		LightingElevation:   65,
+		DepthInfluence:      0.2,
+		Accuracy:            2,
+		Contrast:            1.0,
+		EdgeThreshold:       0.5,
+		GroundShadowOpacity: 0.5,
+		ShadowHardDistance:  10,
+		ShadowFadeDistance:  80,
+		DitherStrength:      1.0,
+		TilingMode:          "normal",
 		Size: geometry.Vector3{
 			X: 20,
 			Y: 30,
@@ -41,3 +47,203 @@ func TestFromJson(t *testing.T) {
 		t.Errorf("Expected %v, got %v", expected, actual)
 	}
 }
+
+func TestFromJson_DitherStrength(t *testing.T) {
+	m, err := FromJson(strings.NewReader(`{"dither_strength": 0}`))
+	if err != nil {
+		t.Fatalf("Could not process manifest: %v", err)
+	}
+
+	if m.DitherStrength != 0 {
+		t.Errorf("Expected dither strength of 0 to be kept, got %v", m.DitherStrength)
+	}
+}
+
+func TestFromJson_InternalSpriteFields(t *testing.T) {
+	m, err := FromJson(strings.NewReader(`{"sprites": [{"angle": 0, "width": 10, "height": 10, "Frame": 2, "TileX": 1, "TileY": 1}]}`))
+	if err != nil {
+		t.Fatalf("Could not process manifest: %v", err)
+	}
+
+	if spr := m.Sprites[0]; spr.Frame != 0 || spr.TileX != 0 || spr.TileY != 0 {
+		t.Errorf("Expected internal sprite fields not to be read from JSON, got %v", spr)
+	}
+}
+
+func TestManifest_GetLights(t *testing.T) {
+	m := Manifest{LightingAngle: 60, LightingElevation: 65}
+	expected := []Light{{Type: LightDirectional, Angle: 60, Elevation: 65, Colour: white, Intensity: 1.0, Shadows: true}}
+
+	if lights := m.GetLights(); !reflect.DeepEqual(lights, expected) {
+		t.Errorf("Expected default lights %v, got %v", expected, lights)
+	}
+
+	m, err := FromJson(strings.NewReader(`{"lights": [{"angle": 10, "elevation": 20},
+		{"type": "point", "intensity": 0.5, "radius": 10, "colour": [255, 0, 0]},
+		{"intensity": 0, "colour": [0, 0, 0]}], "ambient": {"intensity": 0.1}}`))
+	if err != nil {
+		t.Fatalf("Could not process manifest: %v", err)
+	}
+
+	expected = []Light{
+		{Type: LightDirectional, Angle: 10, Elevation: 20, Colour: white, Intensity: 1.0, Radius: defaultLightRadius},
+		{Type: LightPoint, Intensity: 0.5, Radius: 10, Colour: [3]float64{255, 0, 0}},
+		{Type: LightDirectional, Radius: defaultLightRadius},
+	}
+
+	if lights := m.GetLights(); !reflect.DeepEqual(lights, expected) {
+		t.Errorf("Expected configured lights %v, got %v", expected, lights)
+	}
+
+	if c := expected[0].GetColour(); c.R != 1 || c.G != 1 || c.B != 1 {
+		t.Errorf("Expected unset light colour to be white, got %v", c)
+	}
+
+	if c := expected[1].GetColour(); c.R != 1 || c.G != 0 || c.B != 0 {
+		t.Errorf("Expected light colour to be red, got %v", c)
+	}
+
+	if c := expected[2].GetColour(); c.R != 0 || c.G != 0 || c.B != 0 {
+		t.Errorf("Expected light colour to be black, got %v", c)
+	}
+
+	if c := m.Ambient.GetColour(); c.R != 1 || c.G != 1 || c.B != 1 {
+		t.Errorf("Expected unset ambient colour to be white, got %v", c)
+	}
+}
+
+func TestDefinition_GetFrameSprites(t *testing.T) {
+	def := Definition{
+		Manifest: Manifest{Sprites: []Sprite{{Angle: 0}, {Angle: 90}}},
+	}
+
+	if sprites := def.GetFrameSprites(); len(sprites) != 2 {
+		t.Errorf("expected sprites to be unchanged without frames, got %v", sprites)
+	}
+
+	def.Frames = make([]voxelobject.ProcessedVoxelObject, 3)
+	expected := []Sprite{
+		{Angle: 0, Frame: 0}, {Angle: 0, Frame: 1}, {Angle: 0, Frame: 2},
+		{Angle: 90, Frame: 0}, {Angle: 90, Frame: 1}, {Angle: 90, Frame: 2},
+	}
+
+	if sprites := def.GetFrameSprites(); !reflect.DeepEqual(sprites, expected) {
+		t.Errorf("expected %v, got %v", expected, sprites)
+	}
+
+	if def.Manifest.Sprites[0].Frame != 0 || len(def.Manifest.Sprites) != 2 {
+		t.Errorf("getting frame sprites changed the manifest")
+	}
+}
+
+func TestDefinition_GetSprites_Tiles(t *testing.T) {
+	def := Definition{
+		Manifest: Manifest{
+			Size:    geometry.Vector3{X: 128, Y: 64, Z: 48},
+			Sprites: []Sprite{{Angle: 45}, {Angle: 135, ClipMin: &geometry.Point{Z: 16}}},
+			Tiles:   &Tiles{X: 2},
+		},
+	}
+
+	sprites := def.GetSprites()
+	if len(sprites) != 4 {
+		t.Fatalf("expected 4 sprites for 2 tiles, got %d", len(sprites))
+	}
+
+	testCases := []struct {
+		angle        float64
+		tileX, tileY int
+		min, max     geometry.Point
+	}{
+		{45, 0, 0, geometry.Point{}, geometry.Point{X: 63, Y: 63, Z: 48}},
+		{45, 1, 0, geometry.Point{X: 64}, geometry.Point{X: 127, Y: 63, Z: 48}},
+		{135, 0, 0, geometry.Point{Z: 16}, geometry.Point{X: 63, Y: 63, Z: 48}},
+		{135, 1, 0, geometry.Point{X: 64, Z: 16}, geometry.Point{X: 127, Y: 63, Z: 48}},
+	}
+
+	for i, testCase := range testCases {
+		spr := sprites[i]
+		if spr.Angle != testCase.angle || spr.TileX != testCase.tileX || spr.TileY != testCase.tileY {
+			t.Errorf("sprite %d expected tile %d,%d at angle %v, got tile %d,%d at angle %v", i, testCase.tileX, testCase.tileY, testCase.angle, spr.TileX, spr.TileY, spr.Angle)
+		}
+
+		if *spr.ClipMin != testCase.min || *spr.ClipMax != testCase.max {
+			t.Errorf("sprite %d expected clipping %v-%v, got %v-%v", i, testCase.min, testCase.max, *spr.ClipMin, *spr.ClipMax)
+		}
+	}
+
+	if def.Manifest.Sprites[0].ClipMin != nil {
+		t.Errorf("getting tile sprites changed the manifest")
+	}
+}
+
+func TestFromJson_Animation(t *testing.T) {
+	m, err := FromJson(strings.NewReader(`{"animation": {"frames": 4}}`))
+	if err != nil {
+		t.Fatalf("Could not process manifest: %v", err)
+	}
+
+	if m.Animation == nil || m.Animation.Frames != 4 || m.Animation.GetSource() != AnimationSourceModels {
+		t.Errorf("Expected 4 frames from models, got %v", m.Animation)
+	}
+}
+
+func TestPart(t *testing.T) {
+	m, err := FromJson(strings.NewReader(`{"animation": {"frames": 8, "parts": [{"name": "wheel", "min": {"x": 2, "y": 0, "z": 0}, "max": {"x": 5, "y": 1, "z": 3}, "axis": "y"}]}}`))
+	if err != nil {
+		t.Fatalf("Could not process manifest: %v", err)
+	}
+
+	if source := m.Animation.GetSource(); source != AnimationSourceParts {
+		t.Errorf("Expected animation source %s, got %s", AnimationSourceParts, source)
+	}
+
+	part := m.Animation.Parts[0]
+	if pivot := part.GetPivot(); pivot != (geometry.Vector3{X: 4, Y: 1, Z: 2}) {
+		t.Errorf("Expected pivot at centre of part, got %v", pivot)
+	}
+
+	if angle := part.GetAngle(2, 8); angle != 90 {
+		t.Errorf("Expected angle 90 at frame 2 of 8, got %f", angle)
+	}
+}
+
+func TestFromJson_Assembly(t *testing.T) {
+	m, err := FromJson(strings.NewReader(`{"assembly": {"parts": [{"name": "front", "max": {"x": 62, "y": 39, "z": 47}}, {"name": "rear", "file": "rear.vox"}]}}`))
+	if err != nil {
+		t.Fatalf("Could not process manifest: %v", err)
+	}
+
+	if m.Assembly == nil || len(m.Assembly.Parts) != 2 {
+		t.Fatalf("Expected 2 assembly parts, got %v", m.Assembly)
+	}
+
+	front, rear := m.Assembly.Parts[0], m.Assembly.Parts[1]
+	if front.Min != nil || front.Max == nil || front.Max.X != 62 {
+		t.Errorf("Expected front part to have only a maximum, got %v %v", front.Min, front.Max)
+	}
+
+	if rear.File != "rear.vox" || rear.Min != nil || rear.Max != nil {
+		t.Errorf("Expected rear part from rear.vox with no box, got %v", rear)
+	}
+}
+
+func TestFromJson_Slopes(t *testing.T) {
+	m, err := FromJson(strings.NewReader(`{"slopes": true, "size": {"x": 64, "y": 64, "z": 32}, "sprites": [{"angle": 45, "width": 64}]}`))
+	if err != nil {
+		t.Fatalf("Could not process manifest: %v", err)
+	}
+
+	if m.SlopeHeight != 11 || m.Size.Z != 54 {
+		t.Errorf("Expected slope height 11 and size 54, got %d and %f", m.SlopeHeight, m.Size.Z)
+	}
+
+	if !m.TiledNormals || m.TilingMode != "repeat" {
+		t.Errorf("Expected sloped objects to have repeating tiled normals, got %v %s", m.TiledNormals, m.TilingMode)
+	}
+
+	flat, _ := FromJson(strings.NewReader(`{"size": {"x": 64, "y": 64, "z": 32}, "sprites": [{"angle": 45, "width": 64}]}`))
+	if m.Sprites[0].Height <= flat.Sprites[0].Height {
+		t.Errorf("Expected sloped sprites to be taller than %d, got %d", flat.Sprites[0].Height, m.Sprites[0].Height)
+	}
+}
@@ -0,0 +1,62 @@
+package manifest
+
+import (
+	"fmt"
+	"github.com/mattkimber/gorender/internal/geometry"
+	"math"
+)
+
+// MinTurntableStep is the smallest angle between turntable sprites, which
+// limits a turntable to 360 sprites
+const MinTurntableStep = 1.0
+
+// GetTurntableSprites returns sprites viewing the object from every Turntable
+// degrees around it, starting from the first sprite and drawn at the same
+// scale as it
+func (d *Definition) GetTurntableSprites() ([]Sprite, error) {
+	if d.Turntable < MinTurntableStep {
+		return nil, fmt.Errorf("turntable step must be at least %v degrees", MinTurntableStep)
+	}
+
+	m := d.Manifest
+	if len(m.Sprites) == 0 {
+		return nil, fmt.Errorf("turntable needs at least one sprite in the manifest")
+	}
+
+	first := m.Sprites[0]
+
+	var sprites []Sprite
+	for angle := 0.0; angle < 360; angle += d.Turntable {
+		spr := Sprite{
+			Angle:                first.Angle + angle,
+			Flip:                 first.Flip,
+			RenderElevationAngle: first.RenderElevationAngle,
+			Joggle:               first.Joggle,
+		}
+
+		if m.Camera != nil && m.Camera.IsPerspective() {
+			spr.Width, spr.Height = first.Width, first.Height
+		} else if m.Camera == nil || m.Camera.Scale == 0 {
+			spr.Width = max(int(math.Round(float64(first.Width)*m.getSpriteFootprint(spr)/m.getSpriteFootprint(first))), 1)
+		}
+
+		sprites = append(sprites, spr)
+	}
+
+	// Heights, and widths for cameras with a scale, are fitted to the object
+	m.Sprites = sprites
+	m.SetSpriteSizes()
+
+	return m.Sprites, nil
+}
+
+// Get the width of the object across the screen in voxels for a sprite
+func (m *Manifest) getSpriteFootprint(spr Sprite) float64 {
+	if m.Camera != nil {
+		_, right, _ := m.GetCameraAxes(spr)
+		return getProjectedLength(m.Size, right)
+	}
+
+	angle := geometry.DegToRad(spr.Angle)
+	return math.Abs(m.Size.X*math.Sin(angle)) + math.Abs(m.Size.Y*math.Cos(angle))
+}
@@ -0,0 +1,59 @@
+package manifest
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDefinition_GetTurntableSprites(t *testing.T) {
+	testCases := []struct {
+		name     string
+		json     string
+		expected [][2]int
+	}{
+		{"default", `{"size": {"x": 40, "y": 20, "z": 10}, "sprites": [{"angle": 0, "width": 20}]}`, [][2]int{{20, 0}, {40, 0}, {20, 0}, {40, 0}}},
+		{"camera", `{"camera": {"preset": "side_on", "scale": 2}, "size": {"x": 40, "y": 20, "z": 10}, "sprites": [{"angle": 0}]}`, [][2]int{{40, 20}, {80, 20}, {40, 20}, {80, 20}}},
+		{"perspective", `{"camera": {"projection": "perspective"}, "size": {"x": 40, "y": 20, "z": 10}, "sprites": [{"angle": 0, "width": 64}]}`, [][2]int{{64, 64}, {64, 64}, {64, 64}, {64, 64}}},
+	}
+
+	for _, testCase := range testCases {
+		m, err := FromJson(strings.NewReader(testCase.json))
+		if err != nil {
+			t.Fatalf("%s: could not process manifest: %v", testCase.name, err)
+		}
+
+		def := Definition{Manifest: m, Turntable: 90}
+		sprites, err := def.GetTurntableSprites()
+		if err != nil {
+			t.Fatalf("%s: could not get turntable sprites: %v", testCase.name, err)
+		}
+
+		if len(sprites) != len(testCase.expected) {
+			t.Fatalf("%s: expected %d sprites, got %d", testCase.name, len(testCase.expected), len(sprites))
+		}
+
+		for i, spr := range sprites {
+			if spr.Angle != float64(i*90) {
+				t.Errorf("%s: sprite %d expected angle %d, got %f", testCase.name, i, i*90, spr.Angle)
+			}
+
+			// Heights of the default view depend on the elevation, so only check they were set
+			if spr.Width != testCase.expected[i][0] || (testCase.expected[i][1] != 0 && spr.Height != testCase.expected[i][1]) || spr.Height == 0 {
+				t.Errorf("%s: sprite %d expected size %v, got %dx%d", testCase.name, i, testCase.expected[i], spr.Width, spr.Height)
+			}
+		}
+	}
+}
+
+func TestDefinition_GetTurntableSprites_Invalid(t *testing.T) {
+	m, _ := FromJson(strings.NewReader(`{"size": {"x": 40, "y": 20, "z": 10}, "sprites": [{"angle": 0, "width": 20}]}`))
+
+	if _, err := (&Definition{Manifest: m, Turntable: 0.001}).GetTurntableSprites(); err == nil {
+		t.Errorf("expected error for a turntable step below the minimum")
+	}
+
+	m.Sprites = nil
+	if _, err := (&Definition{Manifest: m, Turntable: 5}).GetTurntableSprites(); err == nil {
+		t.Errorf("expected error for a manifest with no sprites")
+	}
+}
@@ -17,6 +17,7 @@ func castFpRay(object voxelobject.ProcessedVoxelObject, loc0 geometry.Vector3, l
 			HasGeometry:           true,
 			Depth:                 int(loc0.Subtract(loc).Length()),
 			ApproachedBoundingBox: approachedBB,
+			Location:              loc,
 		}
 	} else if approachedBB {
 		return RayResult{ApproachedBoundingBox: true}
@@ -149,6 +150,28 @@ func recoverNonSurfaceVoxel(object voxelobject.ProcessedVoxelObject, loc geometr
 	return
 }
 
+// Step through any transparent voxels at the start of the ray, then continue
+// the ray to find whatever lies behind them
+func castBehindTransparent(object voxelobject.ProcessedVoxelObject, loc0 geometry.Vector3, loc geometry.Vector3, ray geometry.Vector3, limits geometry.Vector3, flipY bool) RayResult {
+	bSizeY := object.Size.Y - 1
+
+	for isInsideBoundingVolume(loc, limits) {
+		lx, ly, lz := int(loc.X), int(loc.Y), int(loc.Z)
+		if flipY {
+			ly = bSizeY - ly
+		}
+
+		index := object.Elements[lx][ly][lz].Index
+		if index == 0 || !object.Palette.IsTransparent(index) {
+			return castFpRay(object, loc0, loc, ray, limits, flipY)
+		}
+
+		loc = loc.Add(ray)
+	}
+
+	return RayResult{}
+}
+
 func getIntersectionWithBounds(loc, ray, limits geometry.Vector3) geometry.Vector3 {
 	if canTerminateRay(loc, ray, limits) {
 		return loc
@@ -190,3 +213,32 @@ func canTerminateRay(loc geometry.Vector3, ray geometry.Vector3, limits geometry
 	return (loc.X < 0 && ray.X <= 0) || (loc.Y < 0 && ray.Y <= 0) || (loc.Z < 0 && ray.Z <= 0) ||
 		(loc.X > limits.X && ray.X >= 0) || (loc.Y > limits.Y && ray.Y >= 0) || (loc.Z > limits.Z && ray.Z >= 0)
 }
+
+// Check whether a ray passes through the bounding volume at any point in
+// front of loc, with a voxel of margin on each side.
+func rayIntersectsBounds(loc geometry.Vector3, ray geometry.Vector3, limits geometry.Vector3) bool {
+	near, far := 0.0, math.Inf(1)
+
+	for _, axis := range [][3]float64{{loc.X, ray.X, limits.X}, {loc.Y, ray.Y, limits.Y}, {loc.Z, ray.Z, limits.Z}} {
+		l, r, max := axis[0], axis[1], axis[2]
+
+		if r == 0 {
+			if l < -1 || l > max+1 {
+				return false
+			}
+			continue
+		}
+
+		t0, t1 := (-1-l)/r, (max+1-l)/r
+		if t0 > t1 {
+			t0, t1 = t1, t0
+		}
+
+		near, far = math.Max(near, t0), math.Min(far, t1)
+		if near > far {
+			return false
+		}
+	}
+
+	return true
+}
@@ -43,6 +43,28 @@ func TestCanTerminateRay(t *testing.T) {
 	}
 }
 
+func TestRayIntersectsBounds(t *testing.T) {
+	limits := geometry.Vector3{X: 4, Y: 4, Z: 4}
+
+	testCases := []struct {
+		loc, ray geometry.Vector3
+		expected bool
+	}{
+		{geometry.Vector3{X: 2, Y: 2}, geometry.Vector3{Z: 1}, true},
+		{geometry.Vector3{X: 2, Y: 2, Z: 8}, geometry.Vector3{Z: 1}, false},
+		{geometry.Vector3{X: 8, Y: 2}, geometry.Vector3{Z: 1}, false},
+		{geometry.Vector3{X: 8, Y: 2}, geometry.Vector3{X: -1, Z: 1}, true},
+		{geometry.Vector3{X: 12, Y: 2}, geometry.Vector3{X: -1, Z: 1}, false},
+		{geometry.Vector3{X: -6, Y: -6}, geometry.Vector3{X: 1, Y: 1, Z: 1}, true},
+	}
+
+	for _, testCase := range testCases {
+		if result := rayIntersectsBounds(testCase.loc, testCase.ray, limits); result != testCase.expected {
+			t.Errorf("ray %v from %v intersects %v expected %v, got %v", testCase.ray, testCase.loc, limits, testCase.expected, result)
+		}
+	}
+}
+
 func Test_castFpRay(t *testing.T) {
 	object := getObject("testcube", t)
 	size := object.Size
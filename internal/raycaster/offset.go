@@ -0,0 +1,39 @@
+package raycaster
+
+import (
+	"github.com/mattkimber/gorender/internal/geometry"
+	"github.com/mattkimber/gorender/internal/manifest"
+)
+
+// GetScreenOffset returns how far a point in the sprite moves, in pixels at a
+// scale of 1, when it is moved by delta in object space. Objects of different
+// sizes are centred in their sprites, so this is also how far apart the sprites
+// of two parts of an object need to be drawn for them to line up. Points move
+// by the same amount anywhere in a parallel view, so perspective cameras are
+// not supported.
+func GetScreenOffset(m manifest.Manifest, spr manifest.Sprite, size geometry.Point, delta geometry.Vector3) (x, y float64) {
+	if spr.Flip {
+		delta.Y = -delta.Y
+	}
+
+	viewport, view := getView(m, spr, size)
+	ray := geometry.Zero().Subtract(view)
+
+	across, up := viewport.B.Subtract(viewport.A), viewport.D.Subtract(viewport.A)
+	normal := across.Cross(up)
+
+	// Move the point along the view ray until it is back on the viewport plane
+	projected := delta.Subtract(ray.MultiplyByConstant(delta.Dot(normal) / ray.Dot(normal)))
+
+	x = projected.Dot(across) / across.Dot(across) * float64(spr.Width)
+	y = -projected.Dot(up) / up.Dot(up) * float64(spr.Height)
+	return
+}
+
+// GetScreenPosition returns where a point in object space appears in the
+// sprite, in pixels from the top left at a scale of 1
+func GetScreenPosition(m manifest.Manifest, spr manifest.Sprite, size geometry.Point, point geometry.Vector3) (x, y float64) {
+	delta := point.Subtract(getViewportMidpoint(m, spr.ZError, size))
+	x, y = GetScreenOffset(m, spr, size, delta)
+	return x + float64(spr.Width)/2, y + float64(spr.Height)/2
+}
@@ -0,0 +1,45 @@
+package raycaster
+
+import (
+	"github.com/mattkimber/gorender/internal/geometry"
+	"github.com/mattkimber/gorender/internal/manifest"
+	"math"
+	"testing"
+)
+
+func TestGetScreenOffset(t *testing.T) {
+	m := manifest.Manifest{Size: geometry.Vector3{X: 126, Y: 40, Z: 40}}
+	size := geometry.Point{X: 63, Y: 40, Z: 40}
+	delta := geometry.Vector3{X: 31.5}
+
+	testCases := []struct {
+		angle float64
+		x, y  float64
+	}{
+		{90, 18, 0},
+		{270, -18, 0},
+		{0, 0, -6.116505},
+		{180, 0, 6.116505},
+	}
+
+	for _, testCase := range testCases {
+		spr := manifest.Sprite{Angle: testCase.angle, Width: 72, Height: 40, RenderElevationAngle: 30}
+		x, y := GetScreenOffset(m, spr, size, delta)
+		if math.Abs(x-testCase.x) > 0.001 || math.Abs(y-testCase.y) > 0.001 {
+			t.Errorf("angle %f: expected offset %f,%f, got %f,%f", testCase.angle, testCase.x, testCase.y, x, y)
+		}
+	}
+
+	// Flipped sprites are mirrored along the y axis
+	spr := manifest.Sprite{Angle: 90, Width: 72, Height: 40, RenderElevationAngle: 30}
+	x, _ := GetScreenOffset(m, spr, size, geometry.Vector3{Y: 10})
+	spr.Flip = true
+	if flipX, _ := GetScreenOffset(m, spr, size, geometry.Vector3{Y: 10}); flipX != -x || x == 0 {
+		t.Errorf("expected flipped offset %f, got %f", -x, flipX)
+	}
+
+	spr = manifest.Sprite{Angle: 45, Width: 72, Height: 40, RenderElevationAngle: 30}
+	if x, y := GetScreenOffset(m, spr, size, geometry.Zero()); x != 0 || y != 0 {
+		t.Errorf("expected no offset for no movement, got %f,%f", x, y)
+	}
+}
@@ -1,10 +1,12 @@
 package raycaster
 
 import (
+	"github.com/mattkimber/gorender/internal/colour"
 	"github.com/mattkimber/gorender/internal/geometry"
 	"github.com/mattkimber/gorender/internal/manifest"
 	"github.com/mattkimber/gorender/internal/sampler"
 	"github.com/mattkimber/gorender/internal/voxelobject"
+	"math"
 	"sync"
 )
 
@@ -14,9 +16,14 @@ type RenderSample struct {
 	Collision              bool
 	Index                  byte
 	Normal, AveragedNormal geometry.Vector3
-	Depth, Occlusion       int
+	Depth                  int
+	Occlusion              float64
 	LightAmount            float64
 	Shadowing              float64
+	LightColour            colour.RGB
+	GroundShadow           float64
+	Specular               colour.RGB
+	Behind                 *RenderSample
 	Influence              float64
 	Detail                 float64
 	Count                  int
@@ -29,38 +36,41 @@ type RayResult struct {
 	Depth                 int
 	IsRecovered           bool
 	ApproachedBoundingBox bool
+	Location              geometry.Vector3
 }
 
 type RenderOutput [][]RenderInfo
 
-func GetRaycastOutput(object voxelobject.ProcessedVoxelObject, m manifest.Manifest, spr manifest.Sprite, sampler sampler.Samples) RenderOutput {
-	size := object.Size
+// The maximum number of transparent surfaces a ray can pass through
+const maxTransparentLayers = 4
 
-	// Handle slicing functionality
-	minX, maxX := 0, object.Size.X
-	if m.SliceLength > 0 && m.SliceThreshold > 0 && m.SliceThreshold < object.Size.X {
-		midpoint := (object.Size.X / 2) - (m.SliceLength / 2)
-		minX = midpoint - (m.SliceLength * spr.Slice)
-		maxX = minX + m.SliceLength
+type lightSource struct {
+	Direction geometry.Vector3
+	Position  geometry.Vector3
+	Light     manifest.Light
+}
 
-		// Allow sprites to overlap to avoid edge transparency effects
-		minX -= m.SliceOverlap
-		maxX += m.SliceOverlap
+type lightResult struct {
+	Amount    float64
+	Shadowing float64
+}
 
-		if minX < 0 {
-			minX = 0
-		}
-		if maxX > object.Size.X {
-			maxX = object.Size.X
-		}
-	}
+func GetRaycastOutput(object voxelobject.ProcessedVoxelObject, m manifest.Manifest, spr manifest.Sprite, sampler sampler.Samples) RenderOutput {
+	size := object.Size
+
+	clip := getClipBounds(size, m, spr)
 
 	limits := geometry.Vector3{X: float64(size.X), Y: float64(size.Y), Z: float64(size.Z)}
 
-	viewport := getViewportPlane(spr.Angle, m, spr.ZError, size, float64(spr.RenderElevationAngle))
-	ray := geometry.Zero().Subtract(getRenderDirection(spr.Angle, float64(spr.RenderElevationAngle)))
+	cam := getCamera(m, spr, size)
 
-	lighting := getLightingDirection(spr.Angle+float64(m.LightingAngle), float64(m.LightingElevation), spr.Flip)
+	// The direction towards the viewer in object space, for specular highlights
+	view := cam.direction
+	if spr.Flip {
+		view.Y = -view.Y
+	}
+
+	lights := getLightSources(spr, m)
 	result := make(RenderOutput, len(sampler))
 
 	wg := sync.WaitGroup{}
@@ -77,7 +87,7 @@ func GetRaycastOutput(object voxelobject.ProcessedVoxelObject, m manifest.Manife
 			for y := 0; y < h; y++ {
 				samples := sampler[thisX][y]
 				result[thisX][y] = make(RenderInfo, len(samples))
-				raycastSamples(viewport, &samples, ray, limits, object, m, spr, lighting, result, thisX, y, minX, maxX, joggle)
+				raycastSamples(cam, &samples, view, limits, object, m, spr, lights, result, thisX, y, clip, joggle)
 			}
 			wg.Done()
 		}()
@@ -88,20 +98,47 @@ func GetRaycastOutput(object voxelobject.ProcessedVoxelObject, m manifest.Manife
 	return result
 }
 
+// Get the box of voxels to render for a sprite, from the manifest's slicing
+// settings and the sprite's own clipping box
+func getClipBounds(size geometry.Point, m manifest.Manifest, spr manifest.Sprite) geometry.Bounds {
+	clip := geometry.Bounds{Max: size}
+
+	// Handle slicing functionality
+	if m.SliceLength > 0 && m.SliceThreshold > 0 && m.SliceThreshold < size.X {
+		midpoint := (size.X / 2) - (m.SliceLength / 2)
+		minX := midpoint - (m.SliceLength * spr.Slice)
+		maxX := minX + m.SliceLength
+
+		// Allow sprites to overlap to avoid edge transparency effects
+		slice := geometry.Bounds{Max: size}
+		slice.Min.X, slice.Max.X = minX-m.SliceOverlap, maxX+m.SliceOverlap
+		clip = clip.Intersect(slice)
+	}
+
+	if spr.ClipMin != nil {
+		clip = clip.Intersect(geometry.Bounds{Min: *spr.ClipMin, Max: size})
+	}
+
+	if spr.ClipMax != nil {
+		clip = clip.Intersect(geometry.Bounds{Max: *spr.ClipMax})
+	}
+
+	return clip
+}
+
 func raycastSamples(
-	viewport geometry.Plane,
+	cam camera,
 	samples *sampler.SampleList,
-	ray geometry.Vector3,
+	view geometry.Vector3,
 	limits geometry.Vector3,
 	object voxelobject.ProcessedVoxelObject,
 	m manifest.Manifest,
 	spr manifest.Sprite,
-	lighting geometry.Vector3,
+	lights []lightSource,
 	result RenderOutput,
 	thisX int,
 	y int,
-	minX int,
-	maxX int,
+	clip geometry.Bounds,
 	joggle float64) {
 
 	px, py, pz, pi := 0, 0, 0, 0
@@ -111,13 +148,13 @@ func raycastSamples(
 	}
 
 	for i, s := range *samples {
-		loc0 := viewport.BiLerpWithinPlane(s.Location.X, s.Location.Y)
+		loc0, ray := cam.getRay(s.Location.X, s.Location.Y)
 		loc0.Z += joggle
 		loc := getIntersectionWithBounds(loc0, ray, limits)
 
 		rayResult := castFpRay(object, loc0, loc, ray, limits, spr.Flip)
 
-		if rayResult.HasGeometry && rayResult.X >= minX && rayResult.X <= maxX {
+		if rayResult.HasGeometry && clip.Contains(rayResult.X, rayResult.Y, rayResult.Z) {
 			// Speed up for cases where we already encountered this voxel - reduce the amount of sampling needed
 			// later
 			if rayResult.X == px && rayResult.Y == py && rayResult.Z == pz {
@@ -134,53 +171,324 @@ func raycastSamples(
 				pi = i
 			}
 
-			shadowResult := 0
-			if getLightingValue(object.Elements[rayResult.X][rayResult.Y][rayResult.Z].AveragedNormal, lighting) > m.ShadowThreshold {
-				resultVec := geometry.Vector3{X: float64(rayResult.X), Y: float64(rayResult.Y), Z: float64(rayResult.Z)}
-				shadowLoc := resultVec
+			if cam.perspective {
+				// Every ray has its own direction towards the viewer
+				view = geometry.Zero().Subtract(ray)
+				if spr.Flip {
+					view.Y = -view.Y
+				}
+			}
 
-				shadowVec := geometry.Zero().Subtract(lighting).Normalise()
+			setSample(&result[thisX][y][i], object, rayResult, loc0, ray, view, limits, lights, m, spr.Flip, s.Influence, maxTransparentLayers)
+		} else if m.GroundShadow && !rayResult.HasGeometry && ray.Z < 0 {
+			// Samples which miss the object but look down can see the ground.
+			// Hits outside the clip box are left empty, as the ground there
+			// is covered by another tile.
+			result[thisX][y][i].GroundShadow = getGroundShadow(object, loc0, ray, limits, lights, m.GroundHeight, spr.Flip)
+		} else if !rayResult.ApproachedBoundingBox {
+			// Optimise the outside-bounding-box cases by skipping all further samples
+			break
+		}
+	}
+}
 
-				for {
-					sx, sy, sz := int(shadowLoc.X), int(shadowLoc.Y), int(shadowLoc.Z)
+// Get the proportion of the shadow-casting directional light which the object
+// blocks from reaching the ground plane where the view ray meets it.
+func getGroundShadow(object voxelobject.ProcessedVoxelObject, loc0 geometry.Vector3, ray geometry.Vector3, limits geometry.Vector3, lights []lightSource, height float64, flipY bool) float64 {
+	if ray.Z >= 0 {
+		return 0.0
+	}
 
-					if sx != rayResult.X || sy != rayResult.Y || sz != rayResult.Z {
-						break
-					}
+	ground := loc0.Add(ray.MultiplyByConstant((height - loc0.Z) / ray.Z))
 
-					shadowLoc = shadowLoc.Add(shadowVec)
-				}
+	// The view ray is in render space, but the light directions have already
+	// been flipped into object space
+	if flipY {
+		ground.Y = limits.Y - ground.Y
+	}
 
-				// Don't flip Y when calculating shadows, as it has been pre-flipped on input.
-				shadowResult = castFpRay(object, shadowLoc, shadowLoc, shadowVec, limits, false).Depth
-			}
-			setResult(&result[thisX][y][i], object.Elements[rayResult.X][rayResult.Y][rayResult.Z], lighting, rayResult.Depth, shadowResult, s.Influence, rayResult.IsRecovered, m)
-		} else if !rayResult.ApproachedBoundingBox {
-			// Optimise the outside-bounding-box cases by skipping all further samples
+	total, shadowed := 0.0, 0.0
+
+	for _, l := range lights {
+		if l.Light.Type != manifest.LightDirectional || !l.Light.Shadows {
+			continue
+		}
+
+		total += l.Light.Intensity
+		shadowVec := geometry.Zero().Subtract(l.Direction).Normalise()
+
+		// Most of the ground can't be shadowed, so skip the cast where the
+		// shadow ray misses the object entirely
+		if !rayIntersectsBounds(ground, shadowVec, limits) {
+			continue
+		}
+
+		if castFpRay(object, ground, ground, shadowVec, limits, false).HasGeometry {
+			shadowed += l.Light.Intensity
+		}
+	}
+
+	if total == 0 {
+		return 0.0
+	}
+
+	return shadowed / total
+}
+
+// Set a sample from the voxel a ray hit, and from what is behind it if the voxel is transparent
+func setSample(result *RenderSample, object voxelobject.ProcessedVoxelObject, rayResult RayResult, loc0 geometry.Vector3, ray geometry.Vector3, view geometry.Vector3, limits geometry.Vector3, lights []lightSource, m manifest.Manifest, flipY bool, influence float64, layers int) {
+	element := object.Elements[rayResult.X][rayResult.Y][rayResult.Z]
+	lightResults := getLightResults(object, element, rayResult, lights, limits, m)
+	setResult(result, element, lights, lightResults, rayResult.Depth, influence, rayResult.IsRecovered, m)
+
+	if material := object.Palette.GetMaterial(element.Index); material.Specular > 0 {
+		result.Specular = getSpecular(element, rayResult, lights, lightResults, view, material)
+	}
+
+	// Find what can be seen through transparent voxels
+	if layers > 0 && object.Palette.IsTransparent(element.Index) {
+		if behind := castBehindTransparent(object, loc0, rayResult.Location, ray, limits, flipY); behind.HasGeometry {
+			result.Behind = &RenderSample{}
+			setSample(result.Behind, object, behind, loc0, ray, view, limits, lights, m, flipY, influence, layers-1)
+		}
+	}
+}
+
+// Get how shadowed a voxel is from a directional light. Lights with an angular
+// radius are treated as area lights, with several shadow rays spread over the
+// light's disc so that the edges of shadows form a penumbra.
+func getShadowing(object voxelobject.ProcessedVoxelObject, rayResult RayResult, l lightSource, limits geometry.Vector3, m manifest.Manifest) float64 {
+	shadowVec := geometry.Zero().Subtract(l.Direction).Normalise()
+
+	if m.ShadowSamples <= 1 || l.Light.AngularRadius <= 0 {
+		return getShadowRamp(getShadowLength(object, rayResult, shadowVec, limits), m)
+	}
+
+	tangent, bitangent := shadowVec.Tangents()
+	spread := math.Tan(geometry.DegToRad(l.Light.AngularRadius))
+	rotation := getVoxelRotation(rayResult.X, rayResult.Y, rayResult.Z)
+	goldenAngle := math.Pi * (3.0 - math.Sqrt(5.0))
+
+	shadowing := 0.0
+	for i := 0; i < m.ShadowSamples; i++ {
+		r := spread * math.Sqrt((float64(i)+0.5)/float64(m.ShadowSamples))
+		theta := (float64(i) * goldenAngle) + rotation
+
+		offset := tangent.MultiplyByConstant(r * math.Cos(theta)).Add(bitangent.MultiplyByConstant(r * math.Sin(theta)))
+		shadowing += getShadowRamp(getShadowLength(object, rayResult, shadowVec.Add(offset).Normalise(), limits), m)
+	}
+
+	return shadowing / float64(m.ShadowSamples)
+}
+
+// Rotate the shadow ray pattern by a different amount for each voxel, which
+// trades banding in the penumbra for (repeatable) noise
+func getVoxelRotation(x, y, z int) float64 {
+	hash := uint32(x*73856093) ^ uint32(y*19349663) ^ uint32(z*83492791)
+	return float64(hash%3600) / 3600.0 * 2.0 * math.Pi
+}
+
+// Map the distance to the shadow casting geometry onto an amount of shadow,
+// with nearby geometry casting a hard shadow that fades out with distance
+func getShadowRamp(shadowLength int, m manifest.Manifest) float64 {
+	if shadowLength > 0 && shadowLength < m.ShadowHardDistance {
+		return 1.0
+	} else if shadowLength > 0 && shadowLength < m.ShadowFadeDistance {
+		return float64(m.ShadowFadeDistance-shadowLength) / float64(m.ShadowFadeDistance)
+	}
+
+	return 0.0
+}
+
+func getShadowLength(object voxelobject.ProcessedVoxelObject, rayResult RayResult, shadowVec geometry.Vector3, limits geometry.Vector3) int {
+	resultVec := geometry.Vector3{X: float64(rayResult.X), Y: float64(rayResult.Y), Z: float64(rayResult.Z)}
+	shadowLoc := resultVec
+
+	for {
+		sx, sy, sz := int(shadowLoc.X), int(shadowLoc.Y), int(shadowLoc.Z)
+
+		if sx != rayResult.X || sy != rayResult.Y || sz != rayResult.Z {
 			break
 		}
+
+		shadowLoc = shadowLoc.Add(shadowVec)
+	}
+
+	// Don't flip Y when calculating shadows, as it has been pre-flipped on input.
+	return castFpRay(object, shadowLoc, shadowLoc, shadowVec, limits, false).Depth
+}
+
+func getLightResults(object voxelobject.ProcessedVoxelObject, element voxelobject.ProcessedElement, rayResult RayResult, lights []lightSource, limits geometry.Vector3, m manifest.Manifest) (results []lightResult) {
+	results = make([]lightResult, len(lights))
+
+	for i, l := range lights {
+		if l.Light.Type == manifest.LightPoint || l.Light.Type == manifest.LightSpot {
+			results[i].Amount = getPositionalLightAmount(object, element, rayResult, l, limits)
+			continue
+		}
+
+		shadowing := 0.0
+		if l.Light.Shadows && getLightingValue(element.AveragedNormal, l.Direction) > m.ShadowThreshold {
+			shadowing = getShadowing(object, rayResult, l, limits, m)
+		}
+
+		results[i].Amount, results[i].Shadowing = getLightAndShadow(element, l.Direction, shadowing, m)
 	}
+
+	return
+}
+
+// Point and spot lights only ever add light, so shadowing is folded into the
+// amount rather than darkening the voxel further.
+func getPositionalLightAmount(object voxelobject.ProcessedVoxelObject, element voxelobject.ProcessedElement, rayResult RayResult, l lightSource, limits geometry.Vector3) float64 {
+	voxel := geometry.Vector3{X: float64(rayResult.X) + 0.5, Y: float64(rayResult.Y) + 0.5, Z: float64(rayResult.Z) + 0.5}
+	toLight := l.Position.Subtract(voxel)
+	distance := toLight.Length()
+
+	// This is the voxel the light is in
+	if distance < 1.0 {
+		return 1.0
+	}
+
+	if distance >= l.Light.Radius {
+		return 0.0
+	}
+
+	lighting := geometry.Zero().Subtract(toLight).Normalise()
+	amount := getLightingValue(element.AveragedNormal, lighting)
+	if amount <= 0 {
+		return 0.0
+	}
+
+	// Smooth falloff which reaches zero at the light radius
+	falloff := 1.0 - ((distance * distance) / (l.Light.Radius * l.Light.Radius))
+	amount = amount * falloff * falloff
+
+	if l.Light.Type == manifest.LightSpot {
+		amount = amount * getSpotAmount(l, lighting)
+		if amount <= 0 {
+			return 0.0
+		}
+	}
+
+	if l.Light.Shadows && isShadowedFromPosition(object, rayResult, toLight, distance, limits) {
+		return 0.0
+	}
+
+	return amount
+}
+
+// Get the Blinn-Phong highlight from every light reaching this voxel
+func getSpecular(element voxelobject.ProcessedElement, rayResult RayResult, lights []lightSource, lightResults []lightResult, view geometry.Vector3, material colour.Material) (specular colour.RGB) {
+	// Normals point into the object, so flip to get the outward direction
+	normal := geometry.Zero().Subtract(element.AveragedNormal)
+
+	for i, l := range lights {
+		var toLight geometry.Vector3
+		visibility := 1.0 - lightResults[i].Shadowing
+
+		if l.Light.Type == manifest.LightPoint || l.Light.Type == manifest.LightSpot {
+			voxel := geometry.Vector3{X: float64(rayResult.X) + 0.5, Y: float64(rayResult.Y) + 0.5, Z: float64(rayResult.Z) + 0.5}
+			toLight = l.Position.Subtract(voxel).Normalise()
+
+			// The light amount includes the angle to the surface, which the
+			// highlight doesn't need, so remove it to leave falloff and shadow
+			nDotL := normal.Dot(toLight)
+			if nDotL <= 0 || lightResults[i].Amount <= 0 {
+				continue
+			}
+			visibility = math.Min(lightResults[i].Amount/nDotL, 1.0)
+		} else {
+			toLight = geometry.Zero().Subtract(l.Direction)
+			if normal.Dot(toLight) <= 0 {
+				continue
+			}
+		}
+
+		half := toLight.Add(view).Normalise()
+		highlight := math.Pow(math.Max(normal.Dot(half), 0), material.Shininess)
+		specular = specular.Add(l.Light.GetColour().MultiplyBy(highlight * l.Light.Intensity * visibility * material.Specular))
+	}
+
+	return
 }
 
-func setResult(result *RenderSample, element voxelobject.ProcessedElement, lighting geometry.Vector3, depth int, shadowLength int, influence float64, isRecovered bool, m manifest.Manifest) {
+func getSpotAmount(l lightSource, lighting geometry.Vector3) float64 {
+	cosOuter := math.Cos(geometry.DegToRad(l.Light.ConeAngle))
+	cosInner := math.Cos(geometry.DegToRad(math.Max(l.Light.ConeAngle-l.Light.ConeSoftness, 0)))
+	cosAngle := lighting.Dot(l.Direction)
 
-	if shadowLength > 0 && shadowLength < 10 {
-		result.Shadowing = 1.0
-	} else if shadowLength > 0 && shadowLength < 80 {
-		result.Shadowing = float64(70-(shadowLength-10)) / 80.0
+	if cosAngle <= cosOuter {
+		return 0.0
 	}
 
+	if cosAngle >= cosInner {
+		return 1.0
+	}
+
+	t := (cosAngle - cosOuter) / (cosInner - cosOuter)
+	return t * t * (3.0 - 2.0*t)
+}
+
+func isShadowedFromPosition(object voxelobject.ProcessedVoxelObject, rayResult RayResult, toLight geometry.Vector3, distance float64, limits geometry.Vector3) bool {
+	shadowVec := toLight.Normalise()
+	shadowLoc := geometry.Vector3{X: float64(rayResult.X), Y: float64(rayResult.Y), Z: float64(rayResult.Z)}
+
+	for {
+		sx, sy, sz := int(shadowLoc.X), int(shadowLoc.Y), int(shadowLoc.Z)
+
+		if sx != rayResult.X || sy != rayResult.Y || sz != rayResult.Z {
+			break
+		}
+
+		shadowLoc = shadowLoc.Add(shadowVec)
+	}
+
+	// Anything hit before reaching the voxel the light sits in casts a shadow
+	shadowResult := castFpRay(object, shadowLoc, shadowLoc, shadowVec, limits, false)
+	return shadowResult.HasGeometry && float64(shadowResult.Depth) < distance-1.5
+}
+
+func setResult(result *RenderSample, element voxelobject.ProcessedElement, lights []lightSource, lightResults []lightResult, depth int, influence float64, isRecovered bool, m manifest.Manifest) {
 	result.Collision = true
 	result.Index = element.Index
 	result.Depth = depth
-	result.LightAmount = getLightingValue(element.AveragedNormal, lighting)
-	if result.LightAmount > m.ShadowThreshold {
-		if m.SoftShadow {
-			result.Shadowing = result.Shadowing * (result.LightAmount - m.ShadowThreshold) / (1.0 - m.ShadowThreshold)
+	result.LightAmount = m.Ambient.Intensity
+	result.Shadowing = 0.0
+
+	colourTotal := m.Ambient.GetColour().MultiplyBy(m.Ambient.Intensity)
+	colourInfluence := m.Ambient.Intensity
+
+	for i, l := range lights {
+		lightAmount, shadowing := lightResults[i].Amount, lightResults[i].Shadowing
+
+		// Only the first light darkens the faces turned away from it, so
+		// extra lights can't take away light the others provide
+		if i > 0 {
+			lightAmount = math.Max(0, lightAmount)
+		}
+
+		result.LightAmount += l.Light.Intensity * lightAmount
+		result.Shadowing += l.Light.Intensity * shadowing
+
+		// Lights contribute their colour in proportion to how much they
+		// reach this voxel
+		if lightAmount > 0 {
+			weight := l.Light.Intensity * lightAmount * (1.0 - shadowing)
+			colourTotal = colourTotal.Add(l.Light.GetColour().MultiplyBy(weight))
+			colourInfluence += weight
 		}
-	} else {
-		result.Shadowing = 0.0
 	}
+
+	result.LightColour = colour.RGB{R: 1, G: 1, B: 1}
+	if colourInfluence > 0 {
+		result.LightColour = colour.RGB{
+			R: colourTotal.R / colourInfluence,
+			G: colourTotal.G / colourInfluence,
+			B: colourTotal.B / colourInfluence,
+		}
+	}
+
 	result.Normal = element.Normal
 	result.Occlusion = element.Occlusion
 	result.AveragedNormal = element.AveragedNormal
@@ -190,6 +498,40 @@ func setResult(result *RenderSample, element voxelobject.ProcessedElement, light
 	result.IsRecovered = isRecovered
 }
 
+func getLightAndShadow(element voxelobject.ProcessedElement, lighting geometry.Vector3, shadowing float64, m manifest.Manifest) (lightAmount float64, shadowAmount float64) {
+	lightAmount = getLightingValue(element.AveragedNormal, lighting)
+	if lightAmount > m.ShadowThreshold {
+		shadowAmount = shadowing
+		if m.SoftShadow {
+			shadowAmount = shadowAmount * (lightAmount - m.ShadowThreshold) / (1.0 - m.ShadowThreshold)
+		}
+	}
+
+	return
+}
+
+func getLightSources(spr manifest.Sprite, m manifest.Manifest) (sources []lightSource) {
+	lights := m.GetLights()
+	sources = make([]lightSource, len(lights))
+
+	for i, l := range lights {
+		sources[i] = lightSource{Light: l}
+
+		if l.Type == manifest.LightPoint || l.Type == manifest.LightSpot {
+			// Positional lights are attached to the object, so are given in
+			// object space and don't rotate with the sprite
+			sources[i].Position = l.Position.Add(geometry.Vector3{X: 0.5, Y: 0.5, Z: 0.5})
+			if l.Direction.Length() > 0 {
+				sources[i].Direction = l.Direction.Normalise()
+			}
+		} else {
+			sources[i].Direction = getLightingDirection(m.GetViewAngle(spr)+l.Angle, l.Elevation, spr.Flip)
+		}
+	}
+
+	return
+}
+
 func getLightingValue(normal, lighting geometry.Vector3) float64 {
 	return normal.Dot(lighting)
 }
@@ -1,6 +1,7 @@
 package raycaster
 
 import (
+	gandalfgeo "github.com/mattkimber/gandalf/geometry"
 	"github.com/mattkimber/gandalf/magica"
 	"github.com/mattkimber/gorender/internal/colour"
 	"github.com/mattkimber/gorender/internal/geometry"
@@ -26,6 +27,254 @@ func Test_getLightingValue(t *testing.T) {
 	}
 }
 
+func Test_setResult_Lights(t *testing.T) {
+	element := voxelobject.ProcessedElement{AveragedNormal: geometry.UnitX()}
+	lights := []lightSource{
+		{Direction: geometry.UnitX(), Light: manifest.Light{Intensity: 0.5, Colour: [3]float64{255, 0, 0}}},
+		{Direction: geometry.UnitX(), Light: manifest.Light{Intensity: 0.5, Colour: [3]float64{0, 0, 255}}},
+		{Direction: geometry.UnitX().MultiplyByConstant(-1), Light: manifest.Light{Intensity: 1.0, Colour: [3]float64{0, 255, 0}}},
+	}
+
+	m := manifest.Manifest{Ambient: manifest.AmbientLight{Intensity: 0.25, Colour: [3]float64{255, 255, 255}}}
+
+	var result RenderSample
+	lightResults := []lightResult{{Amount: 1.0}, {Amount: 1.0, Shadowing: 1.0}, {Amount: -1.0}}
+	setResult(&result, element, lights, lightResults, 0, 1.0, false, m)
+
+	// The green light faces away, but isn't the first light so doesn't darken the voxel
+	if result.LightAmount != 1.25 {
+		t.Errorf("expected light amount 1.25, got %v", result.LightAmount)
+	}
+
+	if result.Shadowing != 0.5 {
+		t.Errorf("expected shadowing 0.5, got %v", result.Shadowing)
+	}
+
+	// The shadowed blue light and the green light facing away contribute no colour
+	expected := colour.RGB{R: 0.75 / 0.75, G: 0.25 / 0.75, B: 0.25 / 0.75}
+	if result.LightColour != expected {
+		t.Errorf("expected light colour %v, got %v", expected, result.LightColour)
+	}
+}
+
+func Test_getPositionalLightAmount(t *testing.T) {
+	element := voxelobject.ProcessedElement{AveragedNormal: geometry.UnitX()}
+	rayResult := RayResult{X: 5, Y: 5, Z: 5}
+
+	testCases := []struct {
+		name     string
+		light    lightSource
+		expected float64
+	}{
+		{"inside", lightSource{Position: geometry.Vector3{X: 5.5, Y: 5.5, Z: 5.5}, Light: manifest.Light{Type: manifest.LightPoint, Radius: 10}}, 1.0},
+		{"facing", lightSource{Position: geometry.Vector3{X: 0.5, Y: 5.5, Z: 5.5}, Light: manifest.Light{Type: manifest.LightPoint, Radius: 10}}, 0.5625},
+		{"behind", lightSource{Position: geometry.Vector3{X: 10.5, Y: 5.5, Z: 5.5}, Light: manifest.Light{Type: manifest.LightPoint, Radius: 10}}, 0.0},
+		{"out of range", lightSource{Position: geometry.Vector3{X: 0.5, Y: 5.5, Z: 5.5}, Light: manifest.Light{Type: manifest.LightPoint, Radius: 4}}, 0.0},
+		{"spot in cone", lightSource{Position: geometry.Vector3{X: 0.5, Y: 5.5, Z: 5.5}, Direction: geometry.UnitX(), Light: manifest.Light{Type: manifest.LightSpot, Radius: 10, ConeAngle: 30}}, 0.5625},
+		{"spot outside cone", lightSource{Position: geometry.Vector3{X: 0.5, Y: 5.5, Z: 5.5}, Direction: geometry.UnitY(), Light: manifest.Light{Type: manifest.LightSpot, Radius: 10, ConeAngle: 30}}, 0.0},
+	}
+
+	for _, testCase := range testCases {
+		if result := getPositionalLightAmount(voxelobject.ProcessedVoxelObject{}, element, rayResult, testCase.light, geometry.Vector3{X: 10, Y: 10, Z: 10}); result != testCase.expected {
+			t.Errorf("%s: expected light amount %v, got %v", testCase.name, testCase.expected, result)
+		}
+	}
+}
+
+func Test_getSpecular(t *testing.T) {
+	// An upward facing surface, lit and viewed from directly above
+	element := voxelobject.ProcessedElement{AveragedNormal: geometry.Vector3{Z: -1}}
+	view := geometry.UnitZ()
+	material := colour.Material{Specular: 0.5, Shininess: 16}
+
+	lights := []lightSource{
+		{Direction: geometry.Vector3{Z: -1}, Light: manifest.Light{Type: manifest.LightDirectional, Intensity: 1.0, Colour: [3]float64{255, 0, 0}}},
+		{Direction: geometry.Vector3{Z: -1}, Light: manifest.Light{Type: manifest.LightDirectional, Intensity: 1.0, Colour: [3]float64{0, 255, 0}}},
+		{Direction: geometry.UnitZ(), Light: manifest.Light{Type: manifest.LightDirectional, Intensity: 1.0, Colour: [3]float64{0, 0, 255}}},
+	}
+
+	// The green light is shadowed and the blue light is behind the surface
+	lightResults := []lightResult{{Amount: 1.0}, {Amount: 1.0, Shadowing: 1.0}, {Amount: -1.0}}
+
+	expected := colour.RGB{R: 0.5}
+	if result := getSpecular(element, RayResult{}, lights, lightResults, view, material); result != expected {
+		t.Errorf("expected specular %v, got %v", expected, result)
+	}
+
+	// Moving the viewer away from the reflection direction reduces the highlight
+	if result := getSpecular(element, RayResult{}, lights, lightResults, geometry.Vector3{X: 1, Z: 1}.Normalise(), material); result.R >= expected.R || result.R <= 0 {
+		t.Errorf("expected reduced highlight away from the reflection direction, got %v", result)
+	}
+}
+
+func Test_setSample_Transparent(t *testing.T) {
+	// A pane of glass in front of a solid block
+	mv := magica.NewVoxelObject(gandalfgeo.Point{X: 10, Y: 10, Z: 10}, nil)
+	for y := 0; y < 10; y++ {
+		for z := 0; z < 10; z++ {
+			mv.Voxels[2][y][z] = 12
+			for x := 6; x < 9; x++ {
+				mv.Voxels[x][y][z] = 3
+			}
+		}
+	}
+
+	pal := colour.Palette{Entries: make([]colour.PaletteEntry, 256)}
+	pal.SetRanges([]colour.PaletteRange{{Start: 0, End: 9}, {Start: 10, End: 10, IsTransparent: true}, {Start: 11, End: 255}})
+	object := voxelobject.GetProcessedVoxelObject(mv, &pal, false, "normal", false)
+
+	limits := object.Size.ToVector3()
+	loc0, ray := geometry.Vector3{X: 0.5, Y: 5.5, Z: 5.5}, geometry.UnitX()
+	rayResult := castFpRay(object, loc0, loc0, ray, limits, false)
+
+	var result RenderSample
+	setSample(&result, object, rayResult, loc0, ray, geometry.UnitX(), limits, nil, manifest.Manifest{}, false, 1.0, maxTransparentLayers)
+
+	if result.Index != 10 {
+		t.Fatalf("expected ray to hit glass (10), got %d", result.Index)
+	}
+
+	if result.Behind == nil {
+		t.Fatalf("expected a sample behind the glass")
+	}
+
+	if result.Behind.Index != 1 || result.Behind.Depth <= result.Depth {
+		t.Errorf("expected solid block (1) behind the glass, got %d at depth %d", result.Behind.Index, result.Behind.Depth)
+	}
+
+	// With no layers left the ray stops at the glass
+	result = RenderSample{}
+	setSample(&result, object, rayResult, loc0, ray, geometry.UnitX(), limits, nil, manifest.Manifest{}, false, 1.0, 0)
+	if result.Behind != nil {
+		t.Errorf("expected no sample behind the glass")
+	}
+}
+
+func Test_getGroundShadow(t *testing.T) {
+	object := getObject("testcube", t)
+	limits := object.Size.ToVector3()
+	down := geometry.Vector3{Z: -1}
+
+	lights := []lightSource{
+		{Direction: down, Light: manifest.Light{Type: manifest.LightDirectional, Intensity: 1.0, Shadows: true}},
+		{Direction: geometry.Vector3{X: 1, Z: -1}.Normalise(), Light: manifest.Light{Type: manifest.LightDirectional, Intensity: 1.0, Shadows: true}},
+		{Direction: down, Light: manifest.Light{Type: manifest.LightDirectional, Intensity: 1.0}},
+	}
+
+	testCases := []struct {
+		loc0     geometry.Vector3
+		flip     bool
+		expected float64
+	}{
+		{geometry.Vector3{X: 2, Y: 2, Z: 10}, false, 0.5},
+		{geometry.Vector3{X: 2, Y: 2, Z: 10}, true, 0.5},
+		{geometry.Vector3{X: 3.5, Y: 3.5, Z: 10}, false, 0.0},
+	}
+
+	for _, testCase := range testCases {
+		if result := getGroundShadow(object, testCase.loc0, down, limits, lights, 0, testCase.flip); result != testCase.expected {
+			t.Errorf("ground shadow from %v (flip %v) was %v, expected %v", testCase.loc0, testCase.flip, result, testCase.expected)
+		}
+	}
+
+	if result := getGroundShadow(object, geometry.Vector3{X: 2, Y: 2, Z: 10}, geometry.Vector3{Z: 1}, limits, lights, 0, false); result != 0 {
+		t.Errorf("ground shadow for ray pointing away from the ground was %v, expected 0", result)
+	}
+}
+
+func Test_getShadowRamp(t *testing.T) {
+	m := manifest.Manifest{ShadowHardDistance: 10, ShadowFadeDistance: 80}
+
+	testCases := []struct {
+		length   int
+		expected float64
+	}{
+		{0, 0.0},
+		{5, 1.0},
+		{10, 0.875},
+		{40, 0.5},
+		{80, 0.0},
+		{100, 0.0},
+	}
+
+	for _, testCase := range testCases {
+		if result := getShadowRamp(testCase.length, m); result != testCase.expected {
+			t.Errorf("shadow ramp for length %d was %v, expected %v", testCase.length, result, testCase.expected)
+		}
+	}
+}
+
+func Test_raycaster_SoftShadows(t *testing.T) {
+	// A pillar standing on a floor, which casts a shadow onto the floor
+	mv := magica.NewVoxelObject(gandalfgeo.Point{X: 24, Y: 24, Z: 16}, nil)
+	for x := 0; x < 24; x++ {
+		for y := 0; y < 24; y++ {
+			for z := 0; z < 16; z++ {
+				if z < 4 || (x >= 10 && x < 14 && y >= 10 && y < 14) {
+					mv.Voxels[x][y][z] = 3
+				}
+			}
+		}
+	}
+
+	object := getProcessedObject(mv)
+	m := manifest.Manifest{
+		LightingAngle:         45,
+		LightingElevation:     60,
+		Size:                  object.Size.ToVector3(),
+		RenderElevationAngle:  30,
+		Sprites:               []manifest.Sprite{{Angle: 45, Width: 10, Height: 10, RenderElevationAngle: 30}},
+		ShadowHardDistance:    1000,
+		ShadowFadeDistance:    1000,
+		LightingAngularRadius: 10,
+	}
+
+	smp := sampler.Square(40, 40, 1, 0, 0)
+
+	// A single shadow ray can only give fully lit or fully shadowed voxels
+	output := GetRaycastOutput(object, m, m.Sprites[0], smp)
+	if count := countPartialShadows(output); count != 0 {
+		t.Errorf("expected no partially shadowed samples with one shadow ray, got %d", count)
+	}
+
+	if countFullShadows(output) == 0 {
+		t.Errorf("expected the pillar to cast a shadow")
+	}
+
+	m.ShadowSamples = 16
+	if count := countPartialShadows(GetRaycastOutput(object, m, m.Sprites[0], smp)); count == 0 {
+		t.Errorf("expected partially shadowed samples with an area light")
+	}
+}
+
+func countFullShadows(output RenderOutput) (count int) {
+	for x := range output {
+		for y := range output[x] {
+			for _, s := range output[x][y] {
+				if s.Shadowing == 1 {
+					count++
+				}
+			}
+		}
+	}
+
+	return
+}
+
+func countPartialShadows(output RenderOutput) (count int) {
+	for x := range output {
+		for y := range output[x] {
+			for _, s := range output[x][y] {
+				if s.Shadowing > 0 && s.Shadowing < 1 {
+					count++
+				}
+			}
+		}
+	}
+
+	return
+}
+
 func Test_raycaster(t *testing.T) {
 	object := getObject("cone.vox", t)
 	m := manifest.Manifest{
@@ -50,6 +299,10 @@ func getObject(filename string, t *testing.T) voxelobject.ProcessedVoxelObject {
 		t.Fatalf("error loading test file: %v", err)
 	}
 
+	return getProcessedObject(mv)
+}
+
+func getProcessedObject(mv magica.VoxelObject) voxelobject.ProcessedVoxelObject {
 	entries := make([]colour.PaletteEntry, 256)
 
 	pal := colour.Palette{
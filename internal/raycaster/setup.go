@@ -19,15 +19,110 @@ func getLightingDirection(angle float64, elevation float64, flipY bool) geometry
 	return geometry.Zero().Subtract(geometry.Vector3{X: x, Y: y, Z: z}).Normalise()
 }
 
-func getViewportPlane(angle float64, m manifest.Manifest, zError float64, size geometry.Point, elevationAngle float64) geometry.Plane {
-	cos, sin := math.Cos(geometry.DegToRad(angle)), math.Sin(geometry.DegToRad(angle))
+// A camera casts rays from points on the viewport. Orthographic cameras cast
+// every ray in the same direction, and perspective cameras cast rays outward
+// from a single position through the viewport.
+type camera struct {
+	viewport    geometry.Plane
+	direction   geometry.Vector3
+	perspective bool
+	position    geometry.Vector3
+}
 
-	midpointX := float64(size.X) / 2.0
-	if m.PadToFullLength {
-		midpointX -= ((m.Size.X) - float64(size.X)) / 2.0
+// Get the start and direction of the ray through a point on the viewport
+func (c camera) getRay(u, v float64) (loc0, ray geometry.Vector3) {
+	loc := c.viewport.BiLerpWithinPlane(u, v)
+	if c.perspective {
+		return c.position, loc.Subtract(c.position).Normalise()
 	}
 
-	midpoint := geometry.Vector3{X: midpointX, Y: float64(size.Y) / 2.0, Z: (m.Size.Z - zError) / 2.0}
+	return loc, geometry.Zero().Subtract(c.direction)
+}
+
+// Get the camera to cast rays from for a sprite
+func getCamera(m manifest.Manifest, spr manifest.Sprite, size geometry.Point) camera {
+	if m.Camera != nil && m.Camera.IsPerspective() {
+		return getPerspectiveCamera(m, spr, size)
+	}
+
+	viewport, direction := getView(m, spr, size)
+	return camera{viewport: viewport, direction: direction}
+}
+
+// Get the camera for a perspective view. The viewport is one voxel in front of
+// the camera and sized to give the field of view.
+func getPerspectiveCamera(m manifest.Manifest, spr manifest.Sprite, size geometry.Point) camera {
+	position, target := m.GetCameraPosition(spr, getViewportMidpoint(m, 0, size))
+	forward := target.Subtract(position).Normalise()
+
+	right := geometry.UnitZ().Cross(forward)
+	if right.Length() < 0.001 {
+		// Looking straight up or down, so use the view angle to decide which way is up
+		_, right, _ = m.GetCameraAxes(spr)
+	}
+	right = right.Normalise()
+	up := forward.Cross(right)
+
+	roll := geometry.DegToRad(m.Camera.GetRoll())
+	right, up = right.MultiplyByConstant(math.Cos(roll)).Add(up.MultiplyByConstant(math.Sin(roll))),
+		up.MultiplyByConstant(math.Cos(roll)).Subtract(right.MultiplyByConstant(math.Sin(roll)))
+
+	height := math.Tan(geometry.DegToRad(m.Camera.GetFieldOfView() / 2))
+	right = right.MultiplyByConstant(height * float64(spr.Width) / float64(spr.Height))
+	up = up.MultiplyByConstant(height)
+
+	centre := position.Add(forward)
+	return camera{
+		viewport: geometry.Plane{
+			A: centre.Subtract(right).Subtract(up),
+			B: centre.Add(right).Subtract(up),
+			C: centre.Add(right).Add(up),
+			D: centre.Subtract(right).Add(up),
+		},
+		direction:   geometry.Zero().Subtract(forward),
+		perspective: true,
+		position:    position,
+	}
+}
+
+// Get the viewport to cast rays from for a sprite with parallel rays, and the
+// direction from the object towards the viewer
+func getView(m manifest.Manifest, spr manifest.Sprite, size geometry.Point) (viewport geometry.Plane, direction geometry.Vector3) {
+	if m.Camera != nil {
+		return getCameraViewportPlane(m, spr, size)
+	}
+
+	viewport = getViewportPlane(spr.Angle, m, spr.ZError, size, float64(spr.RenderElevationAngle))
+	direction = getRenderDirection(spr.Angle, float64(spr.RenderElevationAngle))
+	return
+}
+
+// Get the viewport for an orthographic camera. Unlike the default view this is
+// at right angles to the view direction, so it works when looking straight down.
+func getCameraViewportPlane(m manifest.Manifest, spr manifest.Sprite, size geometry.Point) (viewport geometry.Plane, direction geometry.Vector3) {
+	direction, right, up := m.GetCameraAxes(spr)
+	scale := m.GetCameraScale(spr)
+
+	right = right.MultiplyByConstant(float64(spr.Width) / (scale * 2))
+	up = up.MultiplyByConstant(float64(spr.Height) / (scale * 2))
+
+	midpoint := getViewportMidpoint(m, 0, size)
+	viewpoint := midpoint.Add(direction.MultiplyByConstant(m.Size.Length()))
+
+	viewport = geometry.Plane{
+		A: viewpoint.Subtract(right).Subtract(up),
+		B: viewpoint.Add(right).Subtract(up),
+		C: viewpoint.Add(right).Add(up),
+		D: viewpoint.Subtract(right).Add(up),
+	}
+
+	return
+}
+
+func getViewportPlane(angle float64, m manifest.Manifest, zError float64, size geometry.Point, elevationAngle float64) geometry.Plane {
+	cos, sin := math.Cos(geometry.DegToRad(angle)), math.Sin(geometry.DegToRad(angle))
+
+	midpoint := getViewportMidpoint(m, zError, size)
 
 	direction := getRenderDirection(angle, elevationAngle)
 	viewpoint := midpoint.Add(direction.MultiplyByConstant(m.Size.X))
@@ -52,6 +147,16 @@ func getViewportPlane(angle float64, m manifest.Manifest, zError float64, size g
 	return geometry.Plane{A: a, B: b, C: c, D: d}
 }
 
+// Get the point in object space which is at the centre of the sprite
+func getViewportMidpoint(m manifest.Manifest, zError float64, size geometry.Point) geometry.Vector3 {
+	midpointX := float64(size.X) / 2.0
+	if m.PadToFullLength {
+		midpointX -= ((m.Size.X) - float64(size.X)) / 2.0
+	}
+
+	return geometry.Vector3{X: midpointX, Y: float64(size.Y) / 2.0, Z: (m.Size.Z - zError) / 2.0}
+}
+
 func getRenderNormal(angle float64) geometry.Vector3 {
 	x, y := -math.Cos(geometry.DegToRad(angle)), math.Sin(geometry.DegToRad(angle))
 	return geometry.Vector3{X: y, Y: -x}.Normalise()
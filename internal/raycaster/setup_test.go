@@ -64,3 +64,75 @@ func TestGetViewportPlane(t *testing.T) {
 		}
 	}
 }
+
+func TestGetClipBounds(t *testing.T) {
+	size := geometry.Point{X: 126, Y: 40, Z: 40}
+	sliced := manifest.Manifest{SliceThreshold: 64, SliceLength: 32, SliceOverlap: 2}
+
+	testCases := []struct {
+		name     string
+		m        manifest.Manifest
+		spr      manifest.Sprite
+		expected geometry.Bounds
+	}{
+		{"whole object", manifest.Manifest{}, manifest.Sprite{}, geometry.Bounds{Max: size}},
+		{"middle slice", sliced, manifest.Sprite{}, geometry.Bounds{Min: geometry.Point{X: 45}, Max: geometry.Point{X: 81, Y: 40, Z: 40}}},
+		{"front slice", sliced, manifest.Sprite{Slice: -2}, geometry.Bounds{Min: geometry.Point{X: 109}, Max: size}},
+		{"clip box", manifest.Manifest{}, manifest.Sprite{ClipMin: &geometry.Point{X: 10, Y: 20}, ClipMax: &geometry.Point{X: 200, Y: 29, Z: 19}},
+			geometry.Bounds{Min: geometry.Point{X: 10, Y: 20}, Max: geometry.Point{X: 126, Y: 29, Z: 19}}},
+		{"clip box with slice", sliced, manifest.Sprite{ClipMin: &geometry.Point{Z: 20}},
+			geometry.Bounds{Min: geometry.Point{X: 45, Z: 20}, Max: geometry.Point{X: 81, Y: 40, Z: 40}}},
+	}
+
+	for _, testCase := range testCases {
+		if result := getClipBounds(size, testCase.m, testCase.spr); result != testCase.expected {
+			t.Errorf("%s: expected %v, got %v", testCase.name, testCase.expected, result)
+		}
+	}
+}
+
+func TestGetView_Camera(t *testing.T) {
+	m := manifest.Manifest{
+		Size:   geometry.Vector3{X: 40, Y: 20, Z: 10},
+		Camera: &manifest.Camera{Preset: manifest.CameraTopDown, Scale: 2},
+	}
+	spr := manifest.Sprite{Width: 40, Height: 80}
+
+	viewport, direction := getView(m, spr, geometry.Point{X: 40, Y: 20, Z: 10})
+
+	if !direction.Equals(geometry.UnitZ()) {
+		t.Errorf("expected top down camera to look down, got %v", direction)
+	}
+
+	// The viewport is flat, above the object and covers the sprite at 2 pixels per voxel
+	if viewport.A.Z != viewport.C.Z || viewport.A.Z <= 10 {
+		t.Errorf("expected viewport to be level above the object, got %v", viewport)
+	}
+
+	if across, up := viewport.B.Subtract(viewport.A), viewport.D.Subtract(viewport.A); !across.Equals(geometry.Vector3{Y: 20}) || !up.Equals(geometry.Vector3{X: 40}) {
+		t.Errorf("expected viewport 20 voxels across and 40 up, got %v and %v", across, up)
+	}
+}
+
+func TestGetCamera_Perspective(t *testing.T) {
+	m := manifest.Manifest{
+		Size: geometry.Vector3{X: 40, Y: 20, Z: 10},
+		Camera: &manifest.Camera{Preset: manifest.CameraSideOn, Projection: manifest.ProjectionPerspective,
+			FieldOfView: 90, Position: &geometry.Vector3{X: -20, Y: 10, Z: 5}},
+	}
+	spr := manifest.Sprite{Width: 200, Height: 100}
+
+	cam := getCamera(m, spr, geometry.Point{X: 40, Y: 20, Z: 10})
+
+	// The centre ray looks at the centre of the object
+	loc0, ray := cam.getRay(0.5, 0.5)
+	if !loc0.Equals(geometry.Vector3{X: -20, Y: 10, Z: 5}) || !ray.Equals(geometry.UnitX()) {
+		t.Errorf("expected centre ray from the camera along x, got %v %v", loc0, ray)
+	}
+
+	// Rays at the corners spread out by the field of view and aspect ratio
+	_, topLeft := cam.getRay(0, 0)
+	if expected := (geometry.Vector3{X: 1, Y: -2, Z: 1}).Normalise(); !topLeft.Equals(expected) {
+		t.Errorf("expected top left ray %v, got %v", expected, topLeft)
+	}
+}
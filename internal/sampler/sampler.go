@@ -107,19 +107,21 @@ func Square(width, height int, accuracy int, overlap float64, falloff float64) (
 
 const discs = 10
 
-var discCache [][]geometry.Vector2
-
 func Disc(width, height int, accuracy int, overlap float64, falloff float64) (result Samples) {
 	radiusSquared := (0.5 + overlap) * (0.5 + overlap)
 	var location geometry.Vector2
 
+	// The cache is local to this sample set so concurrent renders with
+	// different settings don't share (or race on) each other's discs
+	discCache := make([][]geometry.Vector2, discs)
+
 	result = make([][]SampleList, width)
 	scaleVec := geometry.Vector2{X: float64(width), Y: float64(height)}
 	for i := 0; i < width; i++ {
 		result[i] = make([]SampleList, height)
 		for j := 0; j < height; j++ {
 			loc := geometry.Vector2{X: float64(i) / scaleVec.X, Y: float64(j) / scaleVec.Y}
-			disc := getPoissonDisc(accuracy, overlap)
+			disc := getPoissonDisc(discCache, accuracy, overlap)
 
 			result[i][j] = make(SampleList, len(disc))
 			for k, s := range disc {
@@ -143,11 +145,7 @@ func Disc(width, height int, accuracy int, overlap float64, falloff float64) (re
 }
 
 // Get a poisson disc using the naive/slow dart throwing algorithm
-func getPoissonDisc(accuracy int, overlap float64) []geometry.Vector2 {
-	if discCache == nil {
-		discCache = make([][]geometry.Vector2, discs)
-	}
-
+func getPoissonDisc(discCache [][]geometry.Vector2, accuracy int, overlap float64) []geometry.Vector2 {
 	discNum := rand.Intn(discs)
 	if discCache[discNum] != nil {
 		return discCache[discNum]
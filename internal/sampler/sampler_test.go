@@ -3,6 +3,7 @@ package sampler
 import (
 	"github.com/mattkimber/gorender/internal/geometry"
 	"math"
+	"sync"
 	"testing"
 )
 
@@ -62,3 +63,24 @@ func TestDisc(t *testing.T) {
 		t.Errorf("Disc() = %d, want %d", len(result[0][0]), 9)
 	}
 }
+
+func TestDisc_Concurrent(t *testing.T) {
+	// Run with -race to check discs are not shared between sample sets
+	accuracies := []int{2, 3, 4, 5}
+
+	wg := sync.WaitGroup{}
+	wg.Add(len(accuracies))
+
+	for _, a := range accuracies {
+		accuracy := a
+		go func() {
+			defer wg.Done()
+			result := Disc(4, 4, accuracy, 0, 0.5)
+			if len(result[0][0]) > accuracy*accuracy {
+				t.Errorf("Disc() with accuracy %d returned %d samples", accuracy, len(result[0][0]))
+			}
+		}()
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,135 @@
+package sprite
+
+import (
+	"github.com/mattkimber/gorender/internal/colour"
+	"github.com/mattkimber/gorender/internal/manifest"
+)
+
+// A share of the quantisation error passed on to a pixel which has not yet been
+// dithered. Along is the distance further down the current scan line (a column,
+// as sprites are scanned top to bottom) and across is the number of scan lines
+// further on.
+type diffusionWeight struct {
+	along, across int
+	weight        float64
+}
+
+var diffusionKernels = map[string][]diffusionWeight{
+	manifest.DitherFloydSteinberg: {
+		{1, 0, 7.0 / 16},
+		{-1, 1, 3.0 / 16}, {0, 1, 5.0 / 16}, {1, 1, 1.0 / 16},
+	},
+	// Atkinson only passes on 3/4 of the error, which keeps small details
+	// and flat areas cleaner at the cost of some accuracy
+	manifest.DitherAtkinson: {
+		{1, 0, 1.0 / 8}, {2, 0, 1.0 / 8},
+		{-1, 1, 1.0 / 8}, {0, 1, 1.0 / 8}, {1, 1, 1.0 / 8},
+		{0, 2, 1.0 / 8},
+	},
+	manifest.DitherJarvis: {
+		{1, 0, 7.0 / 48}, {2, 0, 5.0 / 48},
+		{-2, 1, 3.0 / 48}, {-1, 1, 5.0 / 48}, {0, 1, 7.0 / 48}, {1, 1, 5.0 / 48}, {2, 1, 3.0 / 48},
+		{-2, 2, 1.0 / 48}, {-1, 2, 3.0 / 48}, {0, 2, 5.0 / 48}, {1, 2, 3.0 / 48}, {2, 2, 1.0 / 48},
+	},
+	manifest.DitherOrdered: {},
+	manifest.DitherNone:    {},
+}
+
+// OutputPalette is the palette the 8bpp output uses, with quantisers for each
+// kind of colour
+type OutputPalette struct {
+	*colour.Palette
+	regular, primaryCC, secondaryCC colour.Quantiser
+
+	// Maps render palette indexes to the output palette, when they are different
+	indexMap *[256]byte
+}
+
+// GetOutputPalette builds the output palette for a definition. This is the same
+// for every sprite, so it is built once and passed to GetShaderOutput.
+func GetOutputPalette(def *manifest.Definition) (out OutputPalette) {
+	out.Palette = def.OutputPalette()
+	out.regular = colour.NewQuantiser(out.GetRegularPalette(), def.Manifest.ColourDistance)
+	out.primaryCC = colour.NewQuantiser(out.GetPrimaryCompanyColourPalette(), def.Manifest.ColourDistance)
+	out.secondaryCC = colour.NewQuantiser(out.GetSecondaryCompanyColourPalette(), def.Manifest.ColourDistance)
+
+	if out.Palette != &def.Palette {
+		indexMap := def.Palette.GetIndexMap(*out.Palette, def.Manifest.ColourDistance)
+		out.indexMap = &indexMap
+	}
+
+	return
+}
+
+func (out OutputPalette) mapIndex(index byte) byte {
+	if out.indexMap == nil {
+		return index
+	}
+
+	return out.indexMap[index]
+}
+
+// 4x4 Bayer threshold matrix
+var bayerMatrix = [4][4]float64{
+	{0, 8, 2, 10},
+	{12, 4, 14, 6},
+	{3, 11, 1, 9},
+	{15, 7, 13, 5},
+}
+
+// getDiffusionKernel returns the error diffusion weights for the dither setting,
+// falling back to Floyd-Steinberg if the setting is not recognised
+func getDiffusionKernel(dither string) []diffusionWeight {
+	if kernel, ok := diffusionKernels[dither]; ok {
+		return kernel
+	}
+
+	return diffusionKernels[manifest.DitherFloydSteinberg]
+}
+
+func getOrderedThreshold(x, y int) float64 {
+	return (bayerMatrix[x%4][y%4] + 0.5) / 16
+}
+
+// getOrderedIndex chooses between the closest palette colour and its neighbour
+// in the same palette range according to the threshold, so that ordered dithering
+// only ever mixes adjacent shades of the same colour.
+func getOrderedIndex(c colour.RGB, bestIndex byte, q colour.Quantiser, entries []colour.PaletteEntry, threshold, strength float64) byte {
+	rng := entries[bestIndex].Range
+	if rng == nil {
+		return bestIndex
+	}
+
+	palette := q.Palette
+	a := palette[bestIndex]
+	offset := c.Subtract(a)
+
+	chosen, position := bestIndex, 0.0
+
+	for _, i := range []int{int(bestIndex) - 1, int(bestIndex) + 1} {
+		if i < 0 || i >= len(palette) || i >= len(entries) || entries[i].Range != rng || q.IsExcluded(i) {
+			continue
+		}
+
+		step := palette[i].Subtract(a)
+		length := step.R*step.R + step.G*step.G + step.B*step.B
+		if length == 0 {
+			continue
+		}
+
+		// How far the colour is from the closest palette colour towards this one
+		t := (offset.R*step.R + offset.G*step.G + offset.B*step.B) / length
+		if t > position {
+			chosen, position = byte(i), t
+		}
+	}
+
+	// At full strength the position is compared directly against the threshold.
+	// Lower strengths pull the threshold towards 0.5, where the closest colour
+	// always wins.
+	if position > 0.5+(strength*(threshold-0.5)) {
+		return chosen
+	}
+
+	return bestIndex
+}
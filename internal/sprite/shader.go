@@ -19,8 +19,11 @@ type ShaderInfo struct {
 	Occlusion        colour.RGB
 	Lighting         colour.RGB
 	Shadowing        colour.RGB
+	Specular         colour.RGB
 	Detail           colour.RGB
 	Transparency     colour.RGB
+	Emission         colour.RGB
+	GroundShadow     float64
 	Region           int
 	LightingCalcDone bool
 	DitherChecked    bool
@@ -76,6 +79,10 @@ func GetShadowing(s *ShaderInfo) colour.RGB {
 	return s.Shadowing
 }
 
+func GetSpecular(s *ShaderInfo) colour.RGB {
+	return s.Specular
+}
+
 func GetDetail(s *ShaderInfo) colour.RGB {
 	return s.Detail
 }
@@ -97,6 +104,10 @@ func GetMaskIndex(s *ShaderInfo) byte {
 	return 0
 }
 
+func GetGroundShadow(s *ShaderInfo) float64 {
+	return s.GroundShadow
+}
+
 func GetRegion(s *ShaderInfo) colour.RGB {
 	return colour.RGB{
 		R: float64(s.Region % 4 * (65535 / 4)),
@@ -105,7 +116,7 @@ func GetRegion(s *ShaderInfo) colour.RGB {
 	}
 }
 
-func GetShaderOutput(renderOutput raycaster.RenderOutput, spr manifest.Sprite, def *manifest.Definition, width int, height int) (output ShaderOutput) {
+func GetShaderOutput(renderOutput raycaster.RenderOutput, spr manifest.Sprite, def *manifest.Definition, out OutputPalette, width int, height int) (output ShaderOutput) {
 	output = make([][]ShaderInfo, width)
 
 	xoffset, yoffset := int(spr.OffsetX*def.Scale), int(spr.OffsetY*def.Scale)
@@ -153,7 +164,10 @@ func GetShaderOutput(renderOutput raycaster.RenderOutput, spr manifest.Sprite, d
 
 			// Flood fill the region connected to this pixel
 			paletteRange := def.Palette.Entries[output[x][y].ModalIndex].Range
-			info.Range = paletteRange
+
+			// Range stats are gathered from the dithered output, so use the
+			// range of the output palette
+			info.Range = out.Entries[out.mapIndex(output[x][y].ModalIndex)].Range
 
 			identifyRegions(&output, def, currentRegion, x, y, width, height, output[x][y].ModalIndex, &def.Palette, paletteRange)
 
@@ -162,24 +176,31 @@ func GetShaderOutput(renderOutput raycaster.RenderOutput, spr manifest.Sprite, d
 		}
 	}
 
-	// Floyd-Steinberg error rows
-	errCurr := make([]colour.RGB, height+2)
-	errNext := make([]colour.RGB, height+2)
-
-	// Palettes
-	regularPalette := def.Palette.GetRegularPalette()
-	primaryCCPalette := def.Palette.GetPrimaryCompanyColourPalette()
-	secondaryCCPalette := def.Palette.GetSecondaryCompanyColourPalette()
+	// Error diffusion grid
+	errs := make([][]colour.RGB, width)
+	for x := range errs {
+		errs[x] = make([]colour.RGB, height)
+	}
 
 	// Get the first pass dithered output to get the basic sprite, which may have
 	// some flat areas
 	for x := 0; x < width; x++ {
-		for y := 0; y < height; y++ {
+		// Serpentine scanning alternates direction on each column
+		direction := 1
+		if def.Manifest.DitherSerpentine && x%2 == 1 {
+			direction = -1
+		}
+
+		for i := 0; i < height; i++ {
+			y := i
+			if direction < 0 {
+				y = height - 1 - i
+			}
 
-			bestIndex := ditherOutput(def, output, x, y, errCurr, primaryCCPalette, secondaryCCPalette, regularPalette, errNext)
+			bestIndex := ditherOutput(def, output, x, y, direction, errs, out)
 
 			// Update the range stats
-			ditheredRange := def.Palette.Entries[bestIndex].Range
+			ditheredRange := out.Entries[bestIndex].Range
 
 			// Hard reset non-renderable colours for transparent sections
 			if bestIndex == 0 {
@@ -204,18 +225,15 @@ func GetShaderOutput(renderOutput raycaster.RenderOutput, spr manifest.Sprite, d
 				regions[output[x][y].Region] = info
 			}
 		}
-
-		// Swap the next and current error lines
-		errCurr, errNext = errNext, errCurr
 	}
 
 	// "Fosterise" by darkening pixels at the bottom and left.
 	// Do this here so areas don't get affected by the dither algorithm later
 	for x := 0; x < width; x++ {
 		for y := 0; y < height; y++ {
-			paletteRange := def.Palette.Entries[output[x][y].DitheredIndex].Range
-			if paletteRange == nil || paletteRange.IsAnimatedLight || paletteRange.IsNonRenderable {
-				// Don't alter special colours
+			paletteRange := out.Entries[output[x][y].DitheredIndex].Range
+			if paletteRange == nil || paletteRange.IsAnimatedLight || paletteRange.IsNonRenderable || paletteRange.Emissive > 0 {
+				// Don't alter special or emissive colours
 				continue
 			}
 
@@ -230,20 +248,23 @@ func GetShaderOutput(renderOutput raycaster.RenderOutput, spr manifest.Sprite, d
 	// Do the second pass dithered output to add fine detail
 	for x := 0; x < width; x++ {
 		for y := 0; y < height; y++ {
-			paletteRange := def.Palette.Entries[output[x][y].DitheredIndex].Range
-			if paletteRange == nil || paletteRange.IsAnimatedLight || paletteRange.IsNonRenderable {
-				// Don't alter special colours
+			paletteRange := out.Entries[output[x][y].DitheredIndex].Range
+			if paletteRange == nil || paletteRange.IsAnimatedLight || paletteRange.IsNonRenderable || paletteRange.Emissive > 0 {
+				// Don't alter special or emissive colours
 				continue
 			}
 
 			if def.Manifest.DitherFlatAreas {
+				// Flat areas are found in the dithered output, which uses the output palette
+				index := out.mapIndex(output[x][y].ModalIndex)
+
 				minLighting, maxLighting, totalPixels := math.MaxFloat64, 0.0, 0
-				getLightingForSameColourArea(&output, def, x, y, width, height, output[x][y].ModalIndex, &minLighting, &maxLighting, &totalPixels)
+				getLightingForSameColourArea(&output, def, x, y, width, height, index, &minLighting, &maxLighting, &totalPixels)
 
 				if maxLighting-minLighting > 0.0 {
 					// Work out how much to dither so 60% of output is un-dithered, 20% darkened, 20% lightened
 					lightingValues := make([]float64, 0)
-					getLightingValues(&output, def, x, y, width, height, output[x][y].ModalIndex, minLighting, maxLighting, &lightingValues)
+					getLightingValues(&output, def, x, y, width, height, index, minLighting, maxLighting, &lightingValues)
 
 					if len(lightingValues) > 1 {
 						sort.Slice(lightingValues, func(i, j int) bool {
@@ -254,7 +275,7 @@ func GetShaderOutput(renderOutput raycaster.RenderOutput, spr manifest.Sprite, d
 						ditherThresholdHigh := lightingValues[(len(lightingValues)*4)/5]
 
 						if ditherThresholdLow != ditherThresholdHigh {
-							doColourPush(&output, def, x, y, width, height, output[x][y].ModalIndex, &def.Palette, minLighting, maxLighting, ditherThresholdLow, ditherThresholdHigh)
+							doColourPush(&output, def, x, y, width, height, index, out.Palette, minLighting, maxLighting, ditherThresholdLow, ditherThresholdHigh)
 						}
 					}
 				}
@@ -262,66 +283,150 @@ func GetShaderOutput(renderOutput raycaster.RenderOutput, spr manifest.Sprite, d
 		}
 	}
 
+	// Indexes in the output refer to the output palette
+	if out.indexMap != nil {
+		for x := 0; x < width; x++ {
+			for y := 0; y < height; y++ {
+				output[x][y].ModalIndex = out.mapIndex(output[x][y].ModalIndex)
+			}
+		}
+	}
+
+	// Glow only affects the 32bpp output, so is added once all the dithering
+	// which uses the colour values is complete
+	if def.Manifest.GlowStrength > 0 && def.Manifest.GlowRadius > 0 {
+		applyGlow(output, def.Manifest.GlowStrength, def.Manifest.GlowRadius*def.Scale, width, height)
+	}
+
 	return
 }
 
-func ditherOutput(def *manifest.Definition, output ShaderOutput, x int, y int, errCurr []colour.RGB, primaryCCPalette []colour.RGB, secondaryCCPalette []colour.RGB, regularPalette []colour.RGB, errNext []colour.RGB) (bestIndex byte) {
+// Spread the light from emissive pixels onto their neighbours, fading out
+// linearly to nothing at the glow radius.
+func applyGlow(output ShaderOutput, strength float64, radius float64, width, height int) {
+	glow := make([][]colour.RGB, width)
+	for x := range glow {
+		glow[x] = make([]colour.RGB, height)
+	}
+
+	r := int(math.Ceil(radius))
+
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			emission := output[x][y].Emission
+			if emission.R == 0 && emission.G == 0 && emission.B == 0 {
+				continue
+			}
+
+			for i := x - r; i <= x+r; i++ {
+				for j := y - r; j <= y+r; j++ {
+					if i < 0 || i >= width || j < 0 || j >= height || (i == x && j == y) {
+						continue
+					}
+
+					distance := math.Sqrt(float64((i-x)*(i-x) + (j-y)*(j-y)))
+					if distance >= radius {
+						continue
+					}
+
+					glow[i][j] = glow[i][j].Add(emission.MultiplyBy(strength * (1 - distance/radius)))
+				}
+			}
+		}
+	}
+
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			g := glow[x][y]
+			if g.R == 0 && g.G == 0 && g.B == 0 {
+				continue
+			}
+
+			// Glow is additive light, so add it to the existing (alpha weighted)
+			// colour and raise the alpha to show it over transparent areas
+			info := &output[x][y]
+			coverage := colour.Clamp(math.Max(g.R, math.Max(g.G, g.B))/65535, 0, 1)
+			alpha := info.Alpha + (coverage * (1 - info.Alpha))
+
+			c := info.Colour.MultiplyBy(info.Alpha).Add(g).MultiplyBy(1 / alpha)
+			info.Colour = colour.ClampRGB(c)
+			info.Alpha = alpha
+		}
+	}
+}
+
+func ditherOutput(def *manifest.Definition, output ShaderOutput, x int, y int, direction int, errs [][]colour.RGB, out OutputPalette) (bestIndex byte) {
 	var ditherError colour.RGB
+	var palette colour.Quantiser
 
 	rng := def.Palette.Entries[output[x][y].ModalIndex].Range
 	if rng == nil {
 		rng = &colour.PaletteRange{}
 	}
 
+	// Error is not carried on from special colours earlier in the scan line
+	prevY := y - direction
+	ignoreError := prevY >= 0 && prevY < len(output[x]) && def.Palette.IsSpecialColour(output[x][prevY].ModalIndex)
+
 	if output[x][y].Alpha < def.Manifest.EdgeThreshold {
 		bestIndex = 0
-	} else if rng.IsPrimaryCompanyColour {
-		if y > 0 && def.Palette.IsSpecialColour(output[x][y-1].ModalIndex) {
-			ditherError = output[x][y].SpecialColour
-		} else {
-			ditherError = output[x][y].SpecialColour.Add(errCurr[y+1])
-		}
-		bestIndex = getBestIndex(ditherError, primaryCCPalette)
-	} else if rng.IsSecondaryCompanyColour {
-		if y > 0 && def.Palette.IsSpecialColour(output[x][y-1].ModalIndex) {
-			ditherError = output[x][y].SpecialColour
-		} else {
-			ditherError = output[x][y].SpecialColour.Add(errCurr[y+1])
-		}
-		bestIndex = getBestIndex(ditherError, secondaryCCPalette)
 	} else if rng.IsAnimatedLight {
 		output[x][y].IsAnimated = true
 		// Never add error values to special colours
-		bestIndex = output[x][y].ModalIndex
-		ditherError = def.Palette.Entries[bestIndex].GetRGB()
+		bestIndex = out.mapIndex(output[x][y].ModalIndex)
+		ditherError = out.Entries[bestIndex].GetRGB()
 	} else {
-		if y > 0 && def.Palette.IsSpecialColour(output[x][y-1].ModalIndex) {
-			ditherError = output[x][y].Colour
+		c := output[x][y].Colour
+		palette = out.regular
+
+		if rng.IsPrimaryCompanyColour {
+			c, palette = output[x][y].SpecialColour, out.primaryCC
+		} else if rng.IsSecondaryCompanyColour {
+			c, palette = output[x][y].SpecialColour, out.secondaryCC
+		}
+
+		if ignoreError {
+			ditherError = c
 		} else {
-			ditherError = output[x][y].Colour.Add(errCurr[y+1])
+			ditherError = c.Add(errs[x][y])
+		}
+		bestIndex = palette.GetBestIndex(ditherError)
+
+		if def.Manifest.Dither == manifest.DitherOrdered {
+			bestIndex = getOrderedIndex(ditherError, bestIndex, palette, out.Entries, getOrderedThreshold(x, y), def.Manifest.DitherStrength)
 		}
-		bestIndex = getBestIndex(ditherError, regularPalette)
 	}
 
 	output[x][y].DitheredIndex = bestIndex
 
-	if def.Palette.IsSpecialColour(bestIndex) {
+	if out.IsSpecialColour(bestIndex) {
 		output[x][y].IsMaskColour = true
 	}
 
 	resultError := colour.RGB{}
 
 	if output[x][y].Alpha >= def.Manifest.EdgeThreshold {
-		resultError = colour.PermissiveClampRGB(ditherError.Subtract(def.Palette.Entries[bestIndex].GetRGB()))
+		resultError = colour.PermissiveClampRGB(ditherError.Subtract(out.Entries[bestIndex].GetRGB()))
 	}
 
-	// Apply Floyd-Steinberg error
-	errNext[y+0] = errNext[y+0].Add(resultError.MultiplyBy(3.0 / 16))
-	errNext[y+1] = errNext[y+1].Add(resultError.MultiplyBy(5.0 / 16))
-	errNext[y+2] = errNext[y+2].Add(resultError.MultiplyBy(1.0 / 16))
-	errCurr[y+2] = errCurr[y+2].Add(resultError.MultiplyBy(7.0 / 16))
+	if strength := def.Manifest.DitherStrength; strength != 1.0 {
+		resultError = resultError.MultiplyBy(strength)
+	}
+
+	// Pass the error on to the pixels which have not been dithered yet
+	for _, w := range getDiffusionKernel(def.Manifest.Dither) {
+		tx, ty := x+w.across, y+(w.along*direction)
+		if tx >= len(errs) || ty < 0 || ty >= len(errs[tx]) {
+			continue
+		}
+
+		if def.Manifest.DitherConfineToRange && def.Palette.Entries[output[tx][ty].ModalIndex].Range != def.Palette.Entries[output[x][y].ModalIndex].Range {
+			continue
+		}
+
+		errs[tx][ty] = errs[tx][ty].Add(resultError.MultiplyBy(w.weight))
+	}
 
-	errCurr[y+1] = colour.RGB{}
 	return
 }
 
@@ -466,34 +571,11 @@ func doColourPush(output *ShaderOutput, def *manifest.Definition, x, y int, widt
 	return
 }
 
-func getBestIndex(error colour.RGB, palette []colour.RGB) byte {
-	bestIndex, bestSum := 0, math.MaxFloat64
-	for index, p := range palette {
-		if p.R > 65000 && (p.G == 0 || p.G > 65000) && p.B > 65000 {
-			continue
-		}
-
-		sum := squareDiff(error.R, p.R) + squareDiff(error.G, p.G) + squareDiff(error.B, p.B)
-		if sum < bestSum {
-			bestIndex, bestSum = index, sum
-			if sum == 0 {
-				break
-			}
-		}
-	}
-
-	return byte(bestIndex)
-}
-
-func squareDiff(a, b float64) float64 {
-	diff := a - b
-	return diff * diff
-}
-
 func shade(info raycaster.RenderInfo, def *manifest.Definition, prevIndex byte) (output ShaderInfo) {
-	totalInfluence, filledInfluence := 0.0, 0.0
-	filledSamples, totalSamples := 0, 0
-	values := map[byte]float64{}
+	totalInfluence, filledInfluence, coveredInfluence := 0.0, 0.0, 0.0
+	filledSamples, totalSamples, missedSamples := 0, 0, 0
+	groundShadow := 0.0
+	values := [256]float64{}
 	fAccuracy := float64(def.Manifest.Accuracy)
 	hardEdgeThreshold := int(def.Manifest.HardEdgeThreshold * 100.0)
 
@@ -524,11 +606,16 @@ func shade(info raycaster.RenderInfo, def *manifest.Definition, prevIndex byte)
 
 		if s.Collision && def.Palette.IsRenderable(s.Index) {
 			filledInfluence += s.Influence
+			coveredInfluence += s.Influence * Coverage(s, def)
 			filledSamples += s.Count
 
 			output.Colour = output.Colour.Add(Colour(s, def, true, s.Influence))
 			output.SpecialColour = output.SpecialColour.Add(Colour(s, def, false, s.Influence))
 
+			if def.Manifest.GlowStrength > 0 {
+				output.Emission = output.Emission.Add(Emission(s, def, s.Influence))
+			}
+
 			if def.Palette.IsSpecialColour(s.Index) {
 				output.Specialness += 1.0 * s.Influence
 				values[s.Index]++
@@ -547,22 +634,35 @@ func shade(info raycaster.RenderInfo, def *manifest.Definition, prevIndex byte)
 				output.Depth = output.Depth.Add(Depth(s).MultiplyBy(floatCount))
 				output.Occlusion = output.Occlusion.Add(Occlusion(s).MultiplyBy(floatCount))
 				output.Shadowing = output.Shadowing.Add(Shadow(s).MultiplyBy(floatCount))
+				output.Specular = output.Specular.Add(Specular(s).MultiplyBy(floatCount))
 				output.Detail = output.Detail.Add(Detail(s).MultiplyBy(floatCount))
 			}
 		}
 
+		if !s.Collision {
+			missedSamples += s.Count
+			groundShadow += s.GroundShadow * float64(s.Count)
+		}
+
 		totalSamples = totalSamples + s.Count
 	}
 
+	// Ground shadow is only visible where the object isn't, so only
+	// consider the samples which missed it
+	if missedSamples > 0 {
+		groundShadow = groundShadow / float64(missedSamples)
+	}
+
 	mx := 0.0
 	alternateModal := byte(0)
 
+	// Scan in index order so ties always resolve to the lowest index
 	for k, v := range values {
 		if v > mx {
 			mx = v
 			// Store the previous modal
 			alternateModal = output.ModalIndex
-			output.ModalIndex = k
+			output.ModalIndex = byte(k)
 		}
 	}
 
@@ -573,9 +673,11 @@ func shade(info raycaster.RenderInfo, def *manifest.Definition, prevIndex byte)
 
 	// Fewer than hard edge threshold collisions = transparent
 	if totalSamples == 0 || filledSamples*100/totalSamples <= hardEdgeThreshold {
-		return ShaderInfo{}
+		return ShaderInfo{GroundShadow: groundShadow}
 	}
 
+	output.GroundShadow = groundShadow
+
 	// Soften edges means that when only some rays collided (typically near edges
 	// of an object) we fade to transparent. Otherwise objects are hard-edged, which
 	// makes them more likely to suffer aliasing artifacts but also clearer at small
@@ -587,12 +689,18 @@ func shade(info raycaster.RenderInfo, def *manifest.Definition, prevIndex byte)
 		output.Alpha = divisor / totalInfluence
 	}
 
+	// Reduce alpha where transparent voxels don't have anything behind them
+	if coveredInfluence < filledInfluence {
+		output.Alpha = output.Alpha * coveredInfluence / filledInfluence
+	}
+
 	if def.Manifest.FadeToBlack {
 		divisor = totalInfluence
 	}
 
 	output.Colour.DivideAndClamp(divisor)
 	output.SpecialColour.DivideAndClamp(divisor)
+	output.Emission = output.Emission.MultiplyBy(1 / divisor)
 
 	output.Specialness = output.Specialness / divisor
 
@@ -605,6 +713,7 @@ func shade(info raycaster.RenderInfo, def *manifest.Definition, prevIndex byte)
 		output.Depth.DivideAndClamp(debugDivisor)
 		output.Occlusion.DivideAndClamp(debugDivisor)
 		output.Shadowing.DivideAndClamp(debugDivisor)
+		output.Specular.DivideAndClamp(debugDivisor)
 		output.Detail.DivideAndClamp(debugDivisor)
 		output.Transparency = FloatValue(float64(filledSamples) / float64(totalSamples))
 	}
@@ -9,7 +9,68 @@ import (
 
 func Colour(smp raycaster.RenderSample, d *manifest.Definition, resolveSpecialColours bool, influence float64) colour.RGB {
 	lightingOffset := getLightingOffset(smp, d.Manifest.DepthInfluence)
-	return d.Palette.GetLitRGB(smp.Index, lightingOffset, d.Manifest.Brightness, d.Manifest.Contrast, resolveSpecialColours, influence)
+	lightColour := smp.LightColour
+
+	// Emissive colours provide their own light, so are pulled towards their
+	// unlit palette value and away from the colour of the scene lighting
+	if emissive := d.Palette.GetEmissive(smp.Index); emissive > 0 {
+		lightingOffset = lightingOffset * (1 - emissive)
+		lightColour = lightColour.MultiplyBy(1 - emissive).Add(colour.RGB{R: emissive, G: emissive, B: emissive})
+	}
+
+	lit := d.Palette.GetLitRGB(smp.Index, lightingOffset, d.Manifest.Brightness, d.Manifest.Contrast, resolveSpecialColours, influence)
+
+	// Tint by the combined colour of the lights reaching this sample
+	lit = lit.MultiplyByRGB(lightColour)
+
+	// Metallic highlights take on the colour of the surface, others are the
+	// colour of the light
+	if smp.Specular != (colour.RGB{}) {
+		metalness := d.Palette.GetMaterial(smp.Index).Metalness
+		tint := colour.RGB{R: 1, G: 1, B: 1}.MultiplyBy(1 - metalness).Add(d.Palette.GetRGB(smp.Index, resolveSpecialColours).MultiplyBy(metalness / 65535))
+		lit = lit.Add(smp.Specular.MultiplyByRGB(tint).MultiplyBy(65535 * influence))
+	}
+
+	// Blend transparent colours with whatever can be seen through them. Where
+	// there is nothing behind, the colour stays the same and coverage is reduced.
+	if d.Palette.IsTransparent(smp.Index) {
+		opacity := d.Palette.GetOpacity(smp.Index)
+		premultiplied, coverage := lit.MultiplyBy(opacity), opacity
+
+		if smp.Behind != nil {
+			behindCoverage := (1 - opacity) * Coverage(*smp.Behind, d)
+			premultiplied = premultiplied.Add(Colour(*smp.Behind, d, resolveSpecialColours, influence).MultiplyBy(behindCoverage))
+			coverage += behindCoverage
+		}
+
+		lit = premultiplied.MultiplyBy(1 / coverage)
+	}
+
+	return lit
+}
+
+// Get how much of a sample is covered by something, allowing for the
+// transparent voxels which can be seen through
+func Coverage(smp raycaster.RenderSample, d *manifest.Definition) float64 {
+	if !d.Palette.IsTransparent(smp.Index) {
+		return 1.0
+	}
+
+	opacity := d.Palette.GetOpacity(smp.Index)
+	if smp.Behind == nil {
+		return opacity
+	}
+
+	return opacity + ((1 - opacity) * Coverage(*smp.Behind, d))
+}
+
+func Emission(smp raycaster.RenderSample, d *manifest.Definition, influence float64) colour.RGB {
+	emissive := d.Palette.GetEmissive(smp.Index)
+	if emissive == 0 {
+		return colour.RGB{}
+	}
+
+	return Colour(smp, d, true, influence).MultiplyBy(emissive)
 }
 
 func Normal(smp raycaster.RenderSample) colour.RGB {
@@ -28,10 +89,14 @@ func Depth(smp raycaster.RenderSample) colour.RGB {
 }
 
 func Occlusion(smp raycaster.RenderSample) colour.RGB {
-	v := float64(smp.Occlusion * 6000)
+	v := smp.Occlusion * 60000
 	return colour.RGB{R: v, G: v, B: v}
 }
 
+func Specular(smp raycaster.RenderSample) colour.RGB {
+	return smp.Specular.MultiplyBy(65535)
+}
+
 func Shadow(smp raycaster.RenderSample) colour.RGB {
 	v := 65535 - (smp.Shadowing * 65535)
 	return colour.RGB{R: v, G: v, B: v}
@@ -56,7 +121,7 @@ func getLightingOffset(smp raycaster.RenderSample, depthInfluence float64) float
 	lightingOffset := -0.3
 	lightingOffset += smp.LightAmount * 0.6
 	lightingOffset += (-(float64(smp.Depth-120) / 40)) * depthInfluence
-	lightingOffset += (-float64(smp.Occlusion) / 10.0) * 0.3
+	lightingOffset -= smp.Occlusion * 0.3
 	lightingOffset -= smp.Shadowing * 0.2
 
 	lightingOffset = lightingOffset / 1.5
@@ -26,6 +26,15 @@ func Apply32bppSprite(img *image.RGBA, bounds image.Rectangle, loc image.Point,
 	}
 }
 
+func ApplyAlphaSprite(img *image.RGBA, bounds image.Rectangle, loc image.Point, info ShaderOutput, opacity float64, getProperty func(*ShaderInfo) float64) {
+	for x := bounds.Min.X; x < bounds.Max.X; x++ {
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			a := colour.Clamp(getProperty(&info[x][y])*opacity, 0, 1)
+			img.Set(x+loc.X, y+loc.Y, color.NRGBA64{A: uint16(a * 65535)})
+		}
+	}
+}
+
 func ApplyIndexedSprite(img *image.Paletted, bounds image.Rectangle, loc image.Point, info ShaderOutput, getProperty func(*ShaderInfo) byte) {
 	for x := bounds.Min.X; x < bounds.Max.X; x++ {
 		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
@@ -1,6 +1,9 @@
 package sprite
 
 import (
+	"github.com/mattkimber/gorender/internal/colour"
+	"github.com/mattkimber/gorender/internal/manifest"
+	"github.com/mattkimber/gorender/internal/raycaster"
 	"github.com/mattkimber/gorender/internal/utils/imageutils"
 	"image"
 	"image/color"
@@ -24,3 +27,87 @@ func TestApplySprite(t *testing.T) {
 		}
 	}
 }
+
+func TestApplyGlow(t *testing.T) {
+	output := make(ShaderOutput, 5)
+	for x := range output {
+		output[x] = make([]ShaderInfo, 1)
+	}
+
+	output[2][0] = ShaderInfo{Alpha: 1.0, Colour: colour.RGB{R: 65535}, Emission: colour.RGB{R: 65535}}
+	applyGlow(output, 1.0, 2.0, 5, 1)
+
+	if output[2][0].Colour != (colour.RGB{R: 65535}) || output[2][0].Alpha != 1.0 {
+		t.Errorf("emissive pixel altered by its own glow: %v, alpha %f", output[2][0].Colour, output[2][0].Alpha)
+	}
+
+	for _, x := range []int{1, 3} {
+		if output[x][0].Alpha != 0.5 {
+			t.Errorf("expected glow alpha 0.5 at %d, got %f", x, output[x][0].Alpha)
+		}
+
+		if output[x][0].Colour.R != 65535-256 || output[x][0].Colour.G != 256 {
+			t.Errorf("expected red glow at %d, got %v", x, output[x][0].Colour)
+		}
+	}
+
+	for _, x := range []int{0, 4} {
+		if output[x][0].Alpha != 0 {
+			t.Errorf("expected no glow outside radius at %d, got alpha %f", x, output[x][0].Alpha)
+		}
+	}
+}
+
+func TestCoverage(t *testing.T) {
+	pal := colour.Palette{Entries: make([]colour.PaletteEntry, 4)}
+	pal.SetRanges([]colour.PaletteRange{{Start: 1, End: 1}, {Start: 2, End: 2, IsTransparent: true, Opacity: 0.25}, {Start: 3, End: 3, IsTransparent: true, Opacity: 0.5}})
+	def := &manifest.Definition{Palette: pal}
+
+	testCases := []struct {
+		sample   raycaster.RenderSample
+		expected float64
+	}{
+		{raycaster.RenderSample{Index: 1}, 1.0},
+		{raycaster.RenderSample{Index: 2}, 0.25},
+		{raycaster.RenderSample{Index: 2, Behind: &raycaster.RenderSample{Index: 1}}, 1.0},
+		{raycaster.RenderSample{Index: 2, Behind: &raycaster.RenderSample{Index: 3}}, 0.625},
+	}
+
+	for i, testCase := range testCases {
+		if result := Coverage(testCase.sample, def); result != testCase.expected {
+			t.Errorf("case %d: expected coverage %v, got %v", i, testCase.expected, result)
+		}
+	}
+}
+
+func Test_getOrderedIndex(t *testing.T) {
+	pal := colour.Palette{Entries: []colour.PaletteEntry{{}, {}, {R: 100, G: 100, B: 100}, {R: 200, G: 200, B: 200}, {R: 255, G: 255, B: 255}}}
+	pal.SetRanges([]colour.PaletteRange{{Start: 1, End: 3}, {Start: 4, End: 4}})
+	palette := colour.NewQuantiser(pal.GetRegularPalette(), colour.DistanceRGB)
+
+	// 40% of the way from colour 2 to colour 3
+	c := colour.RGB{R: 140 * 257, G: 140 * 257, B: 140 * 257}
+
+	testCases := []struct {
+		threshold, strength float64
+		expected            byte
+	}{
+		{0.3, 1.0, 3},
+		{0.5, 1.0, 2},
+		{0.3, 0.0, 2},
+		{0.2, 0.5, 3},
+		{0.1, 0.2, 2},
+	}
+
+	for _, testCase := range testCases {
+		if result := getOrderedIndex(c, 2, palette, pal.Entries, testCase.threshold, testCase.strength); result != testCase.expected {
+			t.Errorf("Threshold %f strength %f: expected index %d, got %d", testCase.threshold, testCase.strength, testCase.expected, result)
+		}
+	}
+
+	// Never step outside the palette range
+	c = colour.RGB{R: 240 * 257, G: 240 * 257, B: 240 * 257}
+	if result := getOrderedIndex(c, 3, palette, pal.Entries, 0.01, 1.0); result != 3 {
+		t.Errorf("Expected ordered dithering to stay in the palette range, got index %d", result)
+	}
+}
@@ -0,0 +1,68 @@
+package spritesheet
+
+import (
+	"fmt"
+	"github.com/mattkimber/gorender/internal/colour"
+	"github.com/mattkimber/gorender/internal/manifest"
+	"github.com/mattkimber/gorender/internal/sprite"
+	"image"
+	"image/color"
+	"image/gif"
+)
+
+// getAnimationSheets adds an animated preview of the 8bpp output for every
+// sprite which uses animated colours
+func getAnimationSheets(sheets *Spritesheets, def manifest.Definition, spriteInfos []SpriteInfo) {
+	palette := def.OutputPalette()
+
+	for i, info := range spriteInfos {
+		indexes := getAnimatedColours(palette, info)
+		if len(indexes) == 0 {
+			continue
+		}
+
+		sheets.Store(fmt.Sprintf("anim_%d", i), Spritesheet{Animation: getAnimation(palette, info, indexes)})
+	}
+}
+
+// Get the animated colours used by a sprite
+func getAnimatedColours(palette *colour.Palette, info SpriteInfo) (indexes []byte) {
+	used := [256]bool{}
+
+	for x := info.SpriteBounds.Min.X; x < info.SpriteBounds.Max.X; x++ {
+		for y := info.SpriteBounds.Min.Y; y < info.SpriteBounds.Max.Y; y++ {
+			index := sprite.GetIndex(&info.ShaderOutput[x][y])
+			if !used[index] && int(index) < len(palette.Entries) && palette.Entries[index].Range != nil && palette.Entries[index].Range.IsAnimatedLight {
+				used[index] = true
+				indexes = append(indexes, index)
+			}
+		}
+	}
+
+	return
+}
+
+func getAnimation(palette *colour.Palette, info SpriteInfo, indexes []byte) *gif.GIF {
+	img := image.NewPaletted(info.SpriteBounds, palette.GetGoPalette())
+	sprite.ApplyIndexedSprite(img, info.SpriteBounds, image.Point{}, info.ShaderOutput, sprite.GetIndex)
+
+	frames := palette.GetAnimationLength(indexes)
+	anim := &gif.GIF{
+		Image: make([]*image.Paletted, frames),
+		Delay: make([]int, frames),
+	}
+
+	for f := 0; f < frames; f++ {
+		framePalette := colour.Palette{Entries: palette.GetAnimationFrame(f)}.GetGoPalette()
+
+		// Index 0 is the transparent background of the 8bpp output
+		if len(framePalette) > 0 {
+			framePalette[0] = color.Transparent
+		}
+
+		anim.Image[f] = &image.Paletted{Pix: img.Pix, Stride: img.Stride, Rect: img.Rect, Palette: framePalette}
+		anim.Delay[f] = palette.GetAnimationFrameDelay()
+	}
+
+	return anim
+}
@@ -0,0 +1,100 @@
+package spritesheet
+
+import (
+	"github.com/mattkimber/gorender/internal/manifest"
+	"github.com/mattkimber/gorender/internal/utils/imageutils"
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// OpenTTD treats this brightness as leaving a recoloured pixel unchanged
+const defaultBrightness = 128
+
+// getCompanyColourPreviewImage recolours the 32bpp spritesheet using the mask
+// the same way OpenTTD's 32bpp blitters do, with one row of sprites for each
+// pair of company colours.
+func getCompanyColourPreviewImage(def manifest.Definition, img32bpp image.Image, mask *image.Paletted) image.Image {
+	palette := def.OutputPalette()
+
+	pairs, err := palette.GetCompanyColourPairs(def.CompanyColourPreviewColours)
+	if err != nil {
+		return nil
+	}
+
+	bounds := img32bpp.Bounds()
+	rowHeight := bounds.Dy() + int(spriteSpacing*def.Scale)
+
+	img := imageutils.GetUniformImage(image.Rectangle{Max: image.Point{X: bounds.Dx(), Y: rowHeight * len(pairs)}}, color.White)
+
+	for row, pair := range pairs {
+		remap := palette.GetCompanyColourRemap(pair[0], pair[1])
+		offset := image.Point{Y: row * rowHeight}
+
+		draw.Draw(img, bounds.Add(offset), img32bpp, bounds.Min, draw.Src)
+
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+				m := mask.ColorIndexAt(x, y)
+				if m == 0 || int(m) >= len(palette.Entries) {
+					continue
+				}
+
+				c := color.NRGBAModel.Convert(img32bpp.At(x, y)).(color.NRGBA)
+				entry := palette.Entries[remap[m]]
+
+				recoloured := adjustBrightness(color.NRGBA{R: entry.R, G: entry.G, B: entry.B, A: c.A}, getBrightness(c))
+				img.Set(x+offset.X, y+offset.Y, recoloured)
+			}
+		}
+	}
+
+	return img
+}
+
+// The brightness of a masked pixel is the brightest of its channels
+func getBrightness(c color.NRGBA) uint8 {
+	brightness := c.R
+	if c.G > brightness {
+		brightness = c.G
+	}
+	if c.B > brightness {
+		brightness = c.B
+	}
+
+	if brightness == 0 {
+		return defaultBrightness
+	}
+
+	return brightness
+}
+
+// adjustBrightness scales a colour by brightness / 128. Any excess over the
+// maximum in one channel is spread over the others, so very bright colours
+// tend towards white rather than changing hue.
+func adjustBrightness(c color.NRGBA, brightness uint8) color.NRGBA {
+	channels := [3]int{int(c.R), int(c.G), int(c.B)}
+	overbright := 0
+
+	for i := range channels {
+		channels[i] = channels[i] * int(brightness) / defaultBrightness
+		if channels[i] > 255 {
+			overbright += channels[i] - 255
+		}
+	}
+
+	overbright /= 2
+
+	for i, v := range channels {
+		if v >= 255 {
+			channels[i] = 255
+		} else if overbright > 0 {
+			channels[i] = v + (overbright*(255-v))/256
+			if channels[i] > 255 {
+				channels[i] = 255
+			}
+		}
+	}
+
+	return color.NRGBA{R: uint8(channels[0]), G: uint8(channels[1]), B: uint8(channels[2]), A: c.A}
+}
@@ -0,0 +1,55 @@
+package spritesheet
+
+import (
+	"encoding/json"
+	"github.com/mattkimber/gorender/internal/manifest"
+	"github.com/mattkimber/gorender/internal/transform"
+	"io"
+)
+
+// FrameMetadata describes where each frame of an animation is in the
+// spritesheet, so the frames can be found by whatever uses the sprites
+type FrameMetadata struct {
+	Frames  int         `json:"frames"`
+	Slopes  []int       `json:"slopes,omitempty"`
+	Sprites []FrameInfo `json:"sprites"`
+}
+
+type FrameInfo struct {
+	Angle  float64 `json:"angle"`
+	Frame  int     `json:"frame"`
+	X      int     `json:"x"`
+	Width  int     `json:"width"`
+	Height int     `json:"height"`
+}
+
+func getFrameMetadata(def manifest.Definition, spriteInfos []SpriteInfo) *FrameMetadata {
+	metadata := FrameMetadata{Frames: len(def.Frames), Sprites: make([]FrameInfo, len(def.Manifest.Sprites))}
+
+	// Each frame is a slope, so say which
+	if def.Manifest.Slopes {
+		metadata.Slopes = transform.Slopes
+	}
+
+	for i, spr := range def.Manifest.Sprites {
+		metadata.Sprites[i] = FrameInfo{
+			Angle:  spr.Angle,
+			Frame:  spr.Frame,
+			X:      spr.X,
+			Width:  spriteInfos[i].SpriteBounds.Dx(),
+			Height: spriteInfos[i].SpriteBounds.Dy(),
+		}
+	}
+
+	return &metadata
+}
+
+func (m *FrameMetadata) OutputToWriter(w io.Writer) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(data)
+	return err
+}
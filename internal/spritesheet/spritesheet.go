@@ -8,20 +8,25 @@ import (
 	"github.com/mattkimber/gorender/internal/utils/fileutils"
 	"github.com/mattkimber/gorender/internal/utils/imageutils"
 	"github.com/mattkimber/gorender/internal/utils/timingutils"
+	"github.com/mattkimber/gorender/internal/voxelobject"
 	"image"
 	"image/color"
+	"image/gif"
 	"image/png"
 	"io"
 	"sync"
 )
 
 type Spritesheet struct {
-	Image image.Image
+	Image     image.Image
+	Animation *gif.GIF
 }
 
 type Spritesheets struct {
 	sync.RWMutex
-	Data map[string]Spritesheet
+	Data   map[string]Spritesheet
+	Frames *FrameMetadata
+	Tiles  *TileMetadata
 }
 
 type SpriteInfo struct {
@@ -34,6 +39,10 @@ const spriteSpacing = 8
 func GetSpritesheets(def manifest.Definition) (sheets Spritesheets) {
 	sheets.Data = make(map[string]Spritesheet)
 
+	// Sprite positions are written below, so work on a copy in case the
+	// manifest is shared with other renders
+	def.Manifest.Sprites = append([]manifest.Sprite{}, def.GetSprites()...)
+
 	w, h := 0, 0
 	for i, spr := range def.Manifest.Sprites {
 		def.Manifest.Sprites[i].X = w
@@ -49,9 +58,27 @@ func GetSpritesheets(def manifest.Definition) (sheets Spritesheets) {
 
 	raycast(def, spriteInfos)
 
+	if len(def.Frames) > 1 {
+		sheets.Frames = getFrameMetadata(def, spriteInfos)
+	}
+
+	if def.Manifest.Tiles != nil {
+		sheets.Tiles = getTileMetadata(def, spriteInfos)
+	}
+
 	timingutils.Time("Spritesheets", def.Time, func() {
 		getRegularSheets(&sheets, def, bounds, spriteInfos)
 	})
+	if def.AnimationPreview {
+		timingutils.Time("Animation previews", def.Time, func() {
+			getAnimationSheets(&sheets, def, spriteInfos)
+		})
+	}
+	if def.Turntable > 0 {
+		timingutils.Time("Turntable preview", def.Time, func() {
+			getTurntableSheets(&sheets, def)
+		})
+	}
 	if def.Debug {
 		timingutils.Time("Debug output", def.Time, func() {
 			getDebugSheets(&sheets, def, bounds, spriteInfos)
@@ -62,7 +89,7 @@ func GetSpritesheets(def manifest.Definition) (sheets Spritesheets) {
 }
 
 func getDebugSheets(sheets *Spritesheets, def manifest.Definition, bounds image.Rectangle, spriteInfos []SpriteInfo) {
-	debugOutputs := []string{"lighting", "depth", "normals", "occlusion", "shadow", "avg_normals", "detail", "transparency", "region"}
+	debugOutputs := []string{"lighting", "depth", "normals", "occlusion", "shadow", "specular", "avg_normals", "detail", "transparency", "region"}
 	var wg sync.WaitGroup
 	wg.Add(len(debugOutputs) + 1)
 
@@ -106,7 +133,22 @@ func getRegularSheets(sheets *Spritesheets, def manifest.Definition, bounds imag
 		}()
 	}
 
+	if !def.Only8bpp && def.Manifest.GroundShadow {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sheets.Store("ground_shadow", Spritesheet{Image: getGroundShadowSpritesheetImage(def, bounds, spriteInfos)})
+		}()
+	}
+
 	wg.Wait()
+
+	if !def.Only8bpp && def.CompanyColourPreview {
+		mask := sheets.Data["mask"].Image.(*image.Paletted)
+		if preview := getCompanyColourPreviewImage(def, sheets.Data["32bpp"].Image, mask); preview != nil {
+			sheets.Store("cc_preview", Spritesheet{Image: preview})
+		}
+	}
 }
 
 func raycast(def manifest.Definition, spriteInfos []SpriteInfo) {
@@ -120,20 +162,21 @@ func raycast(def manifest.Definition, spriteInfos []SpriteInfo) {
 			smp := smpFunc(rect.Max.X, rect.Max.Y, def.Manifest.Accuracy, def.Manifest.Overlap, 0.5+def.Manifest.Falloff)
 
 			spriteInfos[i].SpriteBounds = rect
-			renderOutputs[i] = raycaster.GetRaycastOutput(def.Object, def.Manifest, spr, smp)
+			renderOutputs[i] = raycaster.GetRaycastOutput(def.GetObject(spr), def.Manifest, spr, smp)
 		}
 	})
 
 	timingutils.Time("Sampling", def.Time, func() {
+		out := sprite.GetOutputPalette(&def)
 		for i, spr := range def.Manifest.Sprites {
 			rect := getSpriteSizeForAngle(spr, def.Scale)
-			spriteInfos[i].ShaderOutput = sprite.GetShaderOutput(renderOutputs[i], spr, &def, rect.Max.X, rect.Max.Y)
+			spriteInfos[i].ShaderOutput = sprite.GetShaderOutput(renderOutputs[i], spr, &def, out, rect.Max.X, rect.Max.Y)
 		}
 	})
 }
 
 func get8bppSpritesheetImage(def manifest.Definition, bounds image.Rectangle, spriteInfos []SpriteInfo, depth string) image.Image {
-	palette := def.Palette.GetGoPalette()
+	palette := def.OutputPalette().GetGoPalette()
 	img := image.NewPaletted(bounds, palette)
 	imageutils.ClearToColourIndex(img, byte(len(palette)-1))
 
@@ -150,7 +193,18 @@ func get32bppSpritesheetImage(def manifest.Definition, bounds image.Rectangle, s
 
 	for i := 0; i < len(def.Manifest.Sprites); i++ {
 		loc := image.Point{X: def.Manifest.Sprites[i].X}
-		applySprite32bpp(img, def, spriteInfos[i], loc, depth)
+		applySprite32bpp(img, def.GetObject(def.Manifest.Sprites[i]), spriteInfos[i], loc, depth)
+	}
+
+	return img
+}
+
+func getGroundShadowSpritesheetImage(def manifest.Definition, bounds image.Rectangle, spriteInfos []SpriteInfo) image.Image {
+	img := imageutils.GetUniformImage(bounds, color.Transparent)
+
+	for i := 0; i < len(def.Manifest.Sprites); i++ {
+		loc := image.Point{X: def.Manifest.Sprites[i].X}
+		sprite.ApplyAlphaSprite(img, spriteInfos[i].SpriteBounds, loc, spriteInfos[i].ShaderOutput, def.Manifest.GroundShadowOpacity, sprite.GetGroundShadow)
 	}
 
 	return img
@@ -166,8 +220,8 @@ func applySprite8bpp(img *image.Paletted, spriteInfo SpriteInfo, loc image.Point
 	return
 }
 
-func applySprite32bpp(img *image.RGBA, def manifest.Definition, spriteInfo SpriteInfo, loc image.Point, depth string) {
-	if def.Object.Invalid() {
+func applySprite32bpp(img *image.RGBA, object voxelobject.ProcessedVoxelObject, spriteInfo SpriteInfo, loc image.Point, depth string) {
+	if object.Invalid() {
 		sprite.ApplyUniformSprite(img, spriteInfo.SpriteBounds, loc)
 	} else if depth == "lighting" {
 		sprite.Apply32bppSprite(img, spriteInfo.SpriteBounds, loc, spriteInfo.ShaderOutput, sprite.GetLighting)
@@ -177,6 +231,8 @@ func applySprite32bpp(img *image.RGBA, def manifest.Definition, spriteInfo Sprit
 		sprite.Apply32bppSprite(img, spriteInfo.SpriteBounds, loc, spriteInfo.ShaderOutput, sprite.GetOcclusion)
 	} else if depth == "shadow" {
 		sprite.Apply32bppSprite(img, spriteInfo.SpriteBounds, loc, spriteInfo.ShaderOutput, sprite.GetShadowing)
+	} else if depth == "specular" {
+		sprite.Apply32bppSprite(img, spriteInfo.SpriteBounds, loc, spriteInfo.ShaderOutput, sprite.GetSpecular)
 	} else if depth == "normals" {
 		sprite.Apply32bppSprite(img, spriteInfo.SpriteBounds, loc, spriteInfo.ShaderOutput, sprite.GetNormal)
 	} else if depth == "avg_normals" {
@@ -195,10 +251,22 @@ func applySprite32bpp(img *image.RGBA, def manifest.Definition, spriteInfo Sprit
 }
 
 func (s Spritesheet) OutputToWriter(w io.Writer) (err error) {
+	if s.Animation != nil {
+		return gif.EncodeAll(w, s.Animation)
+	}
+
 	err = png.Encode(w, s.Image)
 	return
 }
 
+func (s Spritesheet) Extension() string {
+	if s.Animation != nil {
+		return ".gif"
+	}
+
+	return ".png"
+}
+
 func (sheets *Spritesheets) Store(key string, s Spritesheet) {
 	sheets.Lock()
 	sheets.Data[key] = s
@@ -206,11 +274,23 @@ func (sheets *Spritesheets) Store(key string, s Spritesheet) {
 }
 
 func (sheets *Spritesheets) SaveAll(baseFilename string) (err error) {
+	if sheets.Frames != nil {
+		if err = fileutils.WriteToFile(baseFilename+"_frames.json", sheets.Frames); err != nil {
+			return err
+		}
+	}
+
+	if sheets.Tiles != nil {
+		if err = fileutils.WriteToFile(baseFilename+"_tiles.json", sheets.Tiles); err != nil {
+			return err
+		}
+	}
+
 	var wg sync.WaitGroup
 	wg.Add(len(sheets.Data))
 
 	for i, sheet := range sheets.Data {
-		filename := baseFilename + "_" + i + ".png"
+		filename := baseFilename + "_" + i + sheet.Extension()
 		thisSheet := sheet
 		go func() { _ = fileutils.WriteToFile(filename, thisSheet); wg.Done() }()
 	}
@@ -1,6 +1,8 @@
 package spritesheet
 
 import (
+	"bytes"
+	"fmt"
 	"github.com/mattkimber/gandalf/magica"
 	"github.com/mattkimber/gorender/internal/colour"
 	"github.com/mattkimber/gorender/internal/geometry"
@@ -11,6 +13,8 @@ import (
 	"github.com/mattkimber/gorender/internal/utils/imageutils"
 	"github.com/mattkimber/gorender/internal/voxelobject"
 	"image"
+	"image/color"
+	"math"
 	"os"
 	"testing"
 )
@@ -56,6 +60,235 @@ func testSpritesheet(t *testing.T, sheets *Spritesheets, bpp string) {
 	}
 }
 
+func TestGetSpritesheets_Dither(t *testing.T) {
+	palette := getPalette(t)
+
+	object := getDetailTester(t, &palette)
+
+	m := getManifest(t)
+
+	dithers := []string{manifest.DitherFloydSteinberg, manifest.DitherAtkinson, manifest.DitherJarvis, manifest.DitherOrdered, manifest.DitherNone}
+
+	for _, dither := range dithers {
+		for _, serpentine := range []bool{false, true} {
+			def := manifest.Definition{
+				Object:   object,
+				Palette:  palette,
+				Manifest: m,
+				Scale:    1.0,
+				Only8bpp: true,
+			}
+
+			def.Manifest.Dither = dither
+			def.Manifest.DitherSerpentine = serpentine
+			def.Manifest.DitherConfineToRange = true
+
+			first, second := GetSpritesheets(def), GetSpritesheets(def)
+
+			a, b := first.Data["8bpp"].Image.(*image.Paletted), second.Data["8bpp"].Image.(*image.Paletted)
+			if !bytes.Equal(a.Pix, b.Pix) {
+				t.Errorf("%s dithering (serpentine %v) did not give the same output on repeated renders", dither, serpentine)
+			}
+
+			if isBlank(a) {
+				t.Errorf("%s dithering (serpentine %v) gave an empty sprite", dither, serpentine)
+			}
+		}
+	}
+}
+
+func TestGetSpritesheets_ColourDistance(t *testing.T) {
+	palette := getPalette(t)
+
+	object := getDetailTester(t, &palette)
+
+	// Measure all outputs against the same full palette, so the quantisers are
+	// compared fairly
+	entries := make([]colour.RGB, len(palette.Entries))
+	for i, e := range palette.Entries {
+		entries[i] = colour.FromPaletteEntry(e)
+	}
+	judge := colour.NewQuantiser(entries, colour.DistanceCIEDE2000)
+
+	m := getManifest(t)
+	distances := []string{colour.DistanceRGB, colour.DistanceOKLab, colour.DistanceCIEDE2000}
+	totals := make(map[string]float64)
+
+	for _, distance := range distances {
+		def := manifest.Definition{
+			Object:   object,
+			Palette:  palette,
+			Manifest: m,
+			Scale:    1.0,
+		}
+
+		// Isolate the quantisation from other changes to the output
+		def.Manifest.Dither = manifest.DitherNone
+		def.Manifest.Fosterise = false
+		def.Manifest.DitherFlatAreas = false
+		def.Manifest.ColourDistance = distance
+
+		spriteInfos := make([]SpriteInfo, len(def.Manifest.Sprites))
+		raycast(def, spriteInfos)
+
+		for i, info := range spriteInfos {
+			e := getAverageQuantisationError(def, info, judge)
+			t.Logf("%-9s sprite %d (angle %3.0f): average CIEDE2000 error %.3f", distance, i, def.Manifest.Sprites[i].Angle, e)
+			totals[distance] += e
+		}
+	}
+
+	// Without dithering every pixel is quantised independently, so matching by
+	// CIEDE2000 can never be worse than RGB when measured by CIEDE2000
+	if totals[colour.DistanceCIEDE2000] > totals[colour.DistanceRGB] {
+		t.Errorf("CIEDE2000 quantisation error %f was greater than RGB error %f", totals[colour.DistanceCIEDE2000], totals[colour.DistanceRGB])
+	}
+}
+
+func TestGetSpritesheets_TargetPalette(t *testing.T) {
+	palette := getPalette(t)
+	object := getDetailTester(t, &palette)
+
+	// Rendering to a copy of the same palette should give the same output
+	target := getPalette(t)
+
+	def := manifest.Definition{
+		Object:   object,
+		Palette:  palette,
+		Manifest: getManifest(t),
+		Scale:    1.0,
+	}
+
+	expected := GetSpritesheets(def)
+
+	def.TargetPalette = &target
+	result := GetSpritesheets(def)
+
+	for _, sheet := range []string{"8bpp", "mask"} {
+		a, b := expected.Data[sheet].Image.(*image.Paletted), result.Data[sheet].Image.(*image.Paletted)
+		if !bytes.Equal(a.Pix, b.Pix) {
+			t.Errorf("%s output with a matching target palette was different", sheet)
+		}
+	}
+
+	// A different target palette is used for the 8bpp output
+	target.Entries = append([]colour.PaletteEntry{}, target.Entries...)
+	target.Entries[1] = colour.PaletteEntry{R: 1, G: 2, B: 3}
+	result = GetSpritesheets(def)
+
+	if c := result.Data["8bpp"].Image.(*image.Paletted).Palette[1]; c != (color.RGBA{R: 1, G: 2, B: 3, A: 255}) {
+		t.Errorf("8bpp output palette colour 1 expected to come from target palette, got %v", c)
+	}
+}
+
+func TestGetSpritesheets_TargetPaletteFlatAreas(t *testing.T) {
+	palette := getPalette(t)
+	object := getDetailTester(t, &palette)
+
+	m := getManifest(t)
+	m.DitherFlatAreas = true
+
+	def := manifest.Definition{
+		Object:   object,
+		Palette:  palette,
+		Manifest: m,
+		Scale:    1.0,
+		Only8bpp: true,
+	}
+
+	expected := GetSpritesheets(def).Data["8bpp"].Image.(*image.Paletted)
+
+	// Swap the colours of pairs of ranges with the same length and settings,
+	// so the target palette has the same colours at different indexes
+	target := getPalette(t)
+	target.Entries = append([]colour.PaletteEntry{}, target.Entries...)
+	indexMap := [256]byte{}
+	for i := range indexMap {
+		indexMap[i] = byte(i)
+	}
+
+	for _, swap := range [][2]int{{24, 32}, {40, 88}, {136, 154}, {162, 170}} {
+		for i := 0; i < 8; i++ {
+			a, b := swap[0]+i, swap[1]+i
+			target.Entries[a].R, target.Entries[b].R = target.Entries[b].R, target.Entries[a].R
+			target.Entries[a].G, target.Entries[b].G = target.Entries[b].G, target.Entries[a].G
+			target.Entries[a].B, target.Entries[b].B = target.Entries[b].B, target.Entries[a].B
+			indexMap[a], indexMap[b] = byte(b), byte(a)
+		}
+	}
+
+	def.TargetPalette = &target
+	result := GetSpritesheets(def).Data["8bpp"].Image.(*image.Paletted)
+
+	// Flat areas are dithered the same way whichever indexes the colours have
+	for i := range expected.Pix {
+		if indexMap[expected.Pix[i]] != result.Pix[i] {
+			t.Fatalf("pixel %d expected index %d, got %d", i, indexMap[expected.Pix[i]], result.Pix[i])
+		}
+	}
+}
+
+func getDetailTester(t *testing.T, palette *colour.Palette) voxelobject.ProcessedVoxelObject {
+	mv, err := magica.FromFile("../../files/detail_tester.vox")
+	if err != nil {
+		t.Fatalf("error loading test file: %v", err)
+	}
+
+	return voxelobject.GetProcessedVoxelObject(mv, palette, false, "normal", false)
+}
+
+func getManifest(t *testing.T) manifest.Manifest {
+	mFile, err := os.Open("../../files/manifest.json")
+	if err != nil {
+		t.Fatalf("could not open manifest file: %v", err)
+	}
+
+	m, err := manifest.FromJson(mFile)
+	mFile.Close()
+	if err != nil {
+		t.Fatalf("could not read manifest file: %v", err)
+	}
+
+	return m
+}
+
+func getAverageQuantisationError(def manifest.Definition, info SpriteInfo, judge colour.Quantiser) float64 {
+	total, count := 0.0, 0
+
+	for x := range info.ShaderOutput {
+		for y := range info.ShaderOutput[x] {
+			s := info.ShaderOutput[x][y]
+			if s.DitheredIndex == 0 || s.IsAnimated {
+				continue
+			}
+
+			target := s.Colour
+			if def.Palette.IsSpecialColour(s.DitheredIndex) {
+				target = s.SpecialColour
+			}
+
+			total += judge.GetError(target, s.DitheredIndex)
+			count++
+		}
+	}
+
+	if count == 0 {
+		return 0
+	}
+
+	return total / float64(count)
+}
+
+func isBlank(img *image.Paletted) bool {
+	for _, p := range img.Pix {
+		if p != 0 && p != 255 {
+			return false
+		}
+	}
+
+	return true
+}
+
 func Benchmark_32bpp(b *testing.B) {
 	spritesheetImage := get32bppSpritesheetImage
 	benchmarkSpritesheet(b, spritesheetImage, "32bpp")
@@ -88,6 +321,7 @@ func benchmarkSpritesheet(b *testing.B, spritesheetImage func(def manifest.Defin
 		X: int(float64(def.Manifest.Sprites[0].Width) * def.Scale),
 		Y: int(float64(def.Manifest.Sprites[0].Height) * def.Scale),
 	}}
+	out := sprite.GetOutputPalette(&def)
 
 	b.ResetTimer()
 
@@ -97,7 +331,7 @@ func benchmarkSpritesheet(b *testing.B, spritesheetImage func(def manifest.Defin
 		smp := sampler.Disc(rect.Max.X, rect.Max.Y, def.Manifest.Accuracy, 0, 0)
 		spr := manifest.Sprite{OffsetX: 0, OffsetY: 0}
 		ro := raycaster.GetRaycastOutput(def.Object, def.Manifest, def.Manifest.Sprites[0], smp)
-		so := sprite.GetShaderOutput(ro, spr, &def, rect.Max.X, rect.Max.Y)
+		so := sprite.GetShaderOutput(ro, spr, &def, out, rect.Max.X, rect.Max.Y)
 
 		info := SpriteInfo{
 			ShaderOutput: so,
@@ -108,15 +342,15 @@ func benchmarkSpritesheet(b *testing.B, spritesheetImage func(def manifest.Defin
 	}
 }
 
-func getPalette(b *testing.B) colour.Palette {
+func getPalette(tb testing.TB) colour.Palette {
 	pFile, err := os.Open("../../files/ttd_palette.json")
 	if err != nil {
-		b.Fatalf("Could nopt open palette file: %v", err)
+		tb.Fatalf("Could nopt open palette file: %v", err)
 	}
 
 	palette, err := colour.FromJson(pFile)
 	if err != nil {
-		b.Fatalf("Could not open palette file: %v", err)
+		tb.Fatalf("Could not open palette file: %v", err)
 	}
 
 	pFile.Close()
@@ -132,3 +366,268 @@ func getObjectForBenchmark(filename string, b *testing.B) voxelobject.ProcessedV
 	v := voxelobject.GetProcessedVoxelObject(mv, &colour.Palette{}, false, "normal", false)
 	return v
 }
+
+func TestGetSpritesheets_CompanyColourPreview(t *testing.T) {
+	palette := getPalette(t)
+
+	def := manifest.Definition{
+		Object:                      getDetailTester(t, &palette),
+		Palette:                     palette,
+		Manifest:                    getManifest(t),
+		Scale:                       1.0,
+		CompanyColourPreview:        true,
+		CompanyColourPreviewColours: []string{"red", "white"},
+	}
+
+	sheets := GetSpritesheets(def)
+
+	preview, ok := sheets.Data["cc_preview"]
+	if !ok {
+		t.Fatalf("no company colour preview in result")
+	}
+
+	img32bpp := sheets.Data["32bpp"].Image
+	if preview.Image.Bounds().Dx() != img32bpp.Bounds().Dx() || preview.Image.Bounds().Dy() <= img32bpp.Bounds().Dy() {
+		t.Errorf("preview size %v does not have room for one row of sprites %v", preview.Image.Bounds(), img32bpp.Bounds())
+	}
+
+	def.CompanyColourPreviewColours = nil
+	sheets = GetSpritesheets(def)
+
+	if rows := sheets.Data["cc_preview"].Image.Bounds().Dy() / preview.Image.Bounds().Dy(); rows != len(palette.CompanyColours) {
+		t.Errorf("expected %d rows in preview, got %d", len(palette.CompanyColours), rows)
+	}
+}
+
+func Test_adjustBrightness(t *testing.T) {
+	testCases := []struct {
+		colour     color.NRGBA
+		brightness uint8
+		expected   color.NRGBA
+	}{
+		{color.NRGBA{R: 100, G: 50, B: 0, A: 255}, 128, color.NRGBA{R: 100, G: 50, B: 0, A: 255}},
+		{color.NRGBA{R: 100, G: 50, B: 0, A: 255}, 64, color.NRGBA{R: 50, G: 25, B: 0, A: 255}},
+		{color.NRGBA{R: 200, G: 0, B: 0, A: 128}, 255, color.NRGBA{R: 255, G: 70, B: 70, A: 128}},
+	}
+
+	for _, testCase := range testCases {
+		if result := adjustBrightness(testCase.colour, testCase.brightness); result != testCase.expected {
+			t.Errorf("colour %v at brightness %d expected %v, got %v", testCase.colour, testCase.brightness, testCase.expected, result)
+		}
+	}
+}
+
+func TestGetAnimationSheets(t *testing.T) {
+	palette := getPalette(t)
+	def := manifest.Definition{Palette: palette}
+
+	output := make(sprite.ShaderOutput, 2)
+	for x := range output {
+		output[x] = make([]sprite.ShaderInfo, 2)
+	}
+
+	// Only the second sprite has an animated colour
+	still := SpriteInfo{ShaderOutput: output, SpriteBounds: image.Rectangle{Max: image.Point{X: 2, Y: 2}}}
+
+	animated := still
+	animated.ShaderOutput = make(sprite.ShaderOutput, 2)
+	for x := range output {
+		animated.ShaderOutput[x] = append([]sprite.ShaderInfo{}, output[x]...)
+	}
+	animated.ShaderOutput[1][1].DitheredIndex = 245
+
+	sheets := Spritesheets{Data: make(map[string]Spritesheet)}
+	getAnimationSheets(&sheets, def, []SpriteInfo{still, animated})
+
+	if _, ok := sheets.Data["anim_0"]; ok {
+		t.Errorf("animation output for sprite with no animated colours")
+	}
+
+	anim, ok := sheets.Data["anim_1"]
+	if !ok || anim.Animation == nil {
+		t.Fatalf("no animation output for sprite with animated colours")
+	}
+
+	if anim.Extension() != ".gif" {
+		t.Errorf("expected animation to be saved as .gif, got %s", anim.Extension())
+	}
+
+	frames := anim.Animation.Image
+	if length := palette.GetAnimationLength([]byte{245}); len(frames) != length {
+		t.Fatalf("expected %d frames, got %d", length, len(frames))
+	}
+
+	// Colours don't necessarily change every frame, but must by half way through
+	if frames[0].At(1, 1) == frames[len(frames)/2].At(1, 1) {
+		t.Errorf("animated colour did not change between frames")
+	}
+
+	if _, _, _, a := frames[0].At(0, 0).RGBA(); a != 0 {
+		t.Errorf("background of animation expected to be transparent")
+	}
+}
+
+func TestGetSpritesheets_Frames(t *testing.T) {
+	palette := getPalette(t)
+	object := getDetailTester(t, &palette)
+
+	m := getManifest(t)
+	m.Sprites = m.Sprites[:2]
+
+	def := manifest.Definition{
+		Object:   object,
+		Palette:  palette,
+		Manifest: m,
+		Scale:    1.0,
+		Only8bpp: true,
+	}
+
+	single := GetSpritesheets(def)
+	if single.Frames != nil {
+		t.Errorf("frame metadata output for an object with no animation")
+	}
+
+	def.Frames = []voxelobject.ProcessedVoxelObject{object, object, object}
+	animated := GetSpritesheets(def)
+
+	if w, expected := animated.Data["8bpp"].Image.Bounds().Dx(), single.Data["8bpp"].Image.Bounds().Dx()*3; w != expected {
+		t.Errorf("expected spritesheet width %d for 3 frames, got %d", expected, w)
+	}
+
+	if animated.Frames == nil || animated.Frames.Frames != 3 || len(animated.Frames.Sprites) != 6 {
+		t.Fatalf("expected metadata for 6 sprites in 3 frames, got %v", animated.Frames)
+	}
+
+	// Frames of each angle are next to each other
+	for i, info := range animated.Frames.Sprites {
+		if info.Frame != i%3 || info.Angle != m.Sprites[i/3].Angle {
+			t.Errorf("sprite %d expected to be frame %d at angle %v, got frame %d at angle %v", i, i%3, m.Sprites[i/3].Angle, info.Frame, info.Angle)
+		}
+	}
+
+	// Identical frames give identical sprites
+	img := animated.Data["8bpp"].Image.(*image.Paletted)
+	first, second := animated.Frames.Sprites[0], animated.Frames.Sprites[1]
+	for x := 0; x < first.Width; x++ {
+		for y := 0; y < first.Height; y++ {
+			if img.ColorIndexAt(first.X+x, y) != img.ColorIndexAt(second.X+x, y) {
+				t.Fatalf("frames of the same object were rendered differently at %d,%d", x, y)
+			}
+		}
+	}
+}
+
+func Test_applySprite32bpp_InvalidFrame(t *testing.T) {
+	img := imageutils.GetUniformImage(image.Rect(0, 0, 4, 4), color.White)
+	info := SpriteInfo{SpriteBounds: image.Rect(0, 0, 2, 2)}
+
+	// An empty frame is drawn as a placeholder even if the main object is valid
+	applySprite32bpp(img, voxelobject.ProcessedVoxelObject{}, info, image.Point{X: 1}, "32bpp")
+
+	for x := 0; x < 4; x++ {
+		for y := 0; y < 4; y++ {
+			expected := color.RGBAModel.Convert(color.White)
+			if x >= 1 && x < 3 && y < 2 {
+				expected = color.RGBAModel.Convert(color.Black)
+			}
+
+			if c := img.At(x, y); c != expected {
+				t.Errorf("pixel %d,%d expected %v, got %v", x, y, expected, c)
+			}
+		}
+	}
+}
+
+func TestGetSpritesheets_Tiles(t *testing.T) {
+	palette := getPalette(t)
+	object := getDetailTester(t, &palette)
+
+	m := getManifest(t)
+	m.Sprites = m.Sprites[1:2]
+	m.Tiles = &manifest.Tiles{X: 2}
+
+	def := manifest.Definition{
+		Object:   object,
+		Palette:  palette,
+		Manifest: m,
+		Scale:    1.0,
+		Only8bpp: true,
+	}
+
+	sheets := GetSpritesheets(def)
+	if sheets.Tiles == nil || len(sheets.Tiles.Sprites) != 2 {
+		t.Fatalf("expected metadata for 2 tiles, got %v", sheets.Tiles)
+	}
+
+	// The corners of tiles next to each other are on the same 2:1 diagonal
+	// as OpenTTD's tile grid
+	first, second := sheets.Tiles.Sprites[0], sheets.Tiles.Sprites[1]
+	dx, dy := second.OffsetX-first.OffsetX, second.OffsetY-first.OffsetY
+	if dy == 0 || math.Abs(math.Abs(float64(dx))-2*math.Abs(float64(dy))) > 1 {
+		t.Errorf("expected tile offsets to differ along a 2:1 diagonal, got %d,%d", dx, dy)
+	}
+
+	// Every pixel of a tile sprite is also in the sprite for the whole object
+	def.Manifest.Tiles = nil
+	whole := GetSpritesheets(def).Data["8bpp"].Image.(*image.Paletted)
+	tiles := sheets.Data["8bpp"].Image.(*image.Paletted)
+
+	for _, info := range sheets.Tiles.Sprites {
+		for x := 0; x < info.Width; x++ {
+			for y := 0; y < info.Height; y++ {
+				if tiles.ColorIndexAt(info.X+x, y) != 0 && whole.ColorIndexAt(x, y) == 0 {
+					t.Fatalf("tile %d,%d has a pixel at %d,%d outside the object", info.TileX, info.TileY, x, y)
+				}
+			}
+		}
+	}
+}
+
+func TestGetSpritesheets_Turntable(t *testing.T) {
+	palette := getPalette(t)
+
+	def := manifest.Definition{
+		Object:         getDetailTester(t, &palette),
+		Palette:        palette,
+		Manifest:       getManifest(t),
+		Scale:          1.0,
+		Turntable:      45,
+		TurntableScale: 2,
+	}
+
+	sheets := GetSpritesheets(def)
+
+	turntable, ok := sheets.Data["turntable"]
+	if !ok || turntable.Animation == nil {
+		t.Fatalf("no turntable animation in result")
+	}
+
+	if len(turntable.Animation.Image) != 8 {
+		t.Errorf("expected 8 turntable frames, got %d", len(turntable.Animation.Image))
+	}
+
+	bounds := turntable.Animation.Image[0].Bounds()
+	for i, img := range turntable.Animation.Image {
+		if img.Bounds() != bounds {
+			t.Errorf("frame %d has bounds %v, expected %v", i, img.Bounds(), bounds)
+		}
+	}
+
+	if bounds.Dx()%2 != 0 || bounds.Dy()%2 != 0 {
+		t.Errorf("expected frames to be scaled up by 2, got %v", bounds)
+	}
+
+	def.TurntableFrames = true
+	sheets = GetSpritesheets(def)
+
+	for i := 0; i < 8; i++ {
+		frame, ok := sheets.Data[fmt.Sprintf("turntable_%03d", i)]
+		if !ok || frame.Image == nil {
+			t.Fatalf("no turntable frame %d in result", i)
+		}
+
+		if frame.Image.Bounds() != bounds {
+			t.Errorf("frame %d has bounds %v, expected %v", i, frame.Image.Bounds(), bounds)
+		}
+	}
+}
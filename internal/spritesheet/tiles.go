@@ -0,0 +1,84 @@
+package spritesheet
+
+import (
+	"encoding/json"
+	"github.com/mattkimber/gorender/internal/geometry"
+	"github.com/mattkimber/gorender/internal/manifest"
+	"github.com/mattkimber/gorender/internal/raycaster"
+	"io"
+	"math"
+)
+
+// TileMetadata describes where the sprite for each tile of a building is in
+// the spritesheet, and the offsets to draw it at
+type TileMetadata struct {
+	X       int        `json:"x"`
+	Y       int        `json:"y"`
+	Sprites []TileInfo `json:"sprites"`
+}
+
+// TileInfo is the position of one tile's sprite. OffsetX and OffsetY are
+// the position of the top left of the sprite relative to the top corner of
+// the tile's ground, as used by OpenTTD.
+type TileInfo struct {
+	Angle   float64 `json:"angle"`
+	Frame   int     `json:"frame"`
+	TileX   int     `json:"tile_x"`
+	TileY   int     `json:"tile_y"`
+	X       int     `json:"x"`
+	Width   int     `json:"width"`
+	Height  int     `json:"height"`
+	OffsetX int     `json:"offset_x"`
+	OffsetY int     `json:"offset_y"`
+}
+
+func getTileMetadata(def manifest.Definition, spriteInfos []SpriteInfo) *TileMetadata {
+	tiles := def.Manifest.Tiles
+	metadata := TileMetadata{X: tiles.GetX(), Y: tiles.GetY(), Sprites: make([]TileInfo, len(def.Manifest.Sprites))}
+
+	for i, spr := range def.Manifest.Sprites {
+		x, y := getTileCorner(def, spr)
+		metadata.Sprites[i] = TileInfo{
+			Angle:   spr.Angle,
+			Frame:   spr.Frame,
+			TileX:   spr.TileX,
+			TileY:   spr.TileY,
+			X:       spr.X,
+			Width:   spriteInfos[i].SpriteBounds.Dx(),
+			Height:  spriteInfos[i].SpriteBounds.Dy(),
+			OffsetX: -int(math.Round(x * def.Scale)),
+			OffsetY: -int(math.Round(y * def.Scale)),
+		}
+	}
+
+	return &metadata
+}
+
+// Get the position in the sprite of the tile corner which is highest on
+// screen, which is the corner OpenTTD draws tile sprites relative to
+func getTileCorner(def manifest.Definition, spr manifest.Sprite) (x, y float64) {
+	bounds := def.Manifest.GetTileBounds(spr.TileX, spr.TileY)
+	size := def.GetObject(spr).Size
+	y = math.Inf(1)
+
+	for _, cx := range []int{bounds.Min.X, bounds.Max.X + 1} {
+		for _, cy := range []int{bounds.Min.Y, bounds.Max.Y + 1} {
+			corner := geometry.Vector3{X: float64(cx), Y: float64(cy)}
+			if px, py := raycaster.GetScreenPosition(def.Manifest, spr, size, corner); py < y {
+				x, y = px, py
+			}
+		}
+	}
+
+	return
+}
+
+func (m *TileMetadata) OutputToWriter(w io.Writer) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(data)
+	return err
+}
@@ -0,0 +1,87 @@
+package spritesheet
+
+import (
+	"fmt"
+	"github.com/mattkimber/gorender/internal/manifest"
+	"github.com/mattkimber/gorender/internal/sprite"
+	"github.com/mattkimber/gorender/internal/utils/imageutils"
+	"image"
+	"image/color"
+	"image/gif"
+)
+
+// Delay between turntable frames in hundredths of a second
+const turntableFrameDelay = 5
+
+// getTurntableSheets renders the object from every angle in the turntable and
+// adds either an animated GIF of the 8bpp output or a PNG of each sprite.
+// Nothing is added if the turntable settings are not valid.
+func getTurntableSheets(sheets *Spritesheets, def manifest.Definition) {
+	sprites, err := def.GetTurntableSprites()
+	if err != nil {
+		return
+	}
+
+	def.Manifest.Sprites = sprites
+	spriteInfos := make([]SpriteInfo, len(def.Manifest.Sprites))
+	raycast(def, spriteInfos)
+
+	// Every frame is centred on a canvas large enough for the widest and
+	// tallest sprites, so the object doesn't jump around as it turns
+	bounds := image.Rectangle{}
+	for _, info := range spriteInfos {
+		bounds.Max.X = max(bounds.Max.X, info.SpriteBounds.Dx())
+		bounds.Max.Y = max(bounds.Max.Y, info.SpriteBounds.Dy())
+	}
+
+	if def.TurntableFrames {
+		for i, info := range spriteInfos {
+			img := getTurntableImage(def, bounds, info)
+			sheets.Store(fmt.Sprintf("turntable_%03d", i), Spritesheet{Image: imageutils.ScaleNearest(img, def.TurntableScale)})
+		}
+		return
+	}
+
+	anim := &gif.GIF{
+		Image: make([]*image.Paletted, len(spriteInfos)),
+		Delay: make([]int, len(spriteInfos)),
+	}
+
+	for i, info := range spriteInfos {
+		img := getTurntable8bppImage(def, bounds, info)
+		anim.Image[i] = imageutils.ScaleNearest(img, def.TurntableScale).(*image.Paletted)
+		anim.Delay[i] = turntableFrameDelay
+	}
+
+	sheets.Store("turntable", Spritesheet{Animation: anim})
+}
+
+func getTurntableImage(def manifest.Definition, bounds image.Rectangle, info SpriteInfo) image.Image {
+	if def.Only8bpp {
+		return getTurntable8bppImage(def, bounds, info)
+	}
+
+	img := imageutils.GetUniformImage(bounds, color.Transparent)
+	sprite.Apply32bppSprite(img, info.SpriteBounds, getTurntableLocation(bounds, info), info.ShaderOutput, sprite.GetColour)
+	return img
+}
+
+func getTurntable8bppImage(def manifest.Definition, bounds image.Rectangle, info SpriteInfo) *image.Paletted {
+	palette := def.OutputPalette().GetGoPalette()
+
+	// Index 0 is the transparent background of the 8bpp output
+	if len(palette) > 0 {
+		palette[0] = color.Transparent
+	}
+
+	img := image.NewPaletted(bounds, palette)
+	sprite.ApplyIndexedSprite(img, info.SpriteBounds, getTurntableLocation(bounds, info), info.ShaderOutput, sprite.GetIndex)
+	return img
+}
+
+func getTurntableLocation(bounds image.Rectangle, info SpriteInfo) image.Point {
+	return image.Point{
+		X: (bounds.Dx() - info.SpriteBounds.Dx()) / 2,
+		Y: (bounds.Dy() - info.SpriteBounds.Dy()) / 2,
+	}
+}
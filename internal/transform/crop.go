@@ -0,0 +1,30 @@
+package transform
+
+import (
+	gandalfgeo "github.com/mattkimber/gandalf/geometry"
+	"github.com/mattkimber/gandalf/magica"
+	"github.com/mattkimber/gorender/internal/geometry"
+)
+
+// Crop returns the voxels of the object inside the box. The result is only
+// cropped in X and Y, so the ground stays at the same height and parts of an
+// object cropped from the same scene are framed the same way vertically.
+func Crop(o magica.VoxelObject, box geometry.Bounds) magica.VoxelObject {
+	box = box.Intersect(GetBounds(o))
+	from, to := box.Min, box.Max
+	from.Z, to.Z = 0, o.Size.Z-1
+
+	size := gandalfgeo.Point{X: max(to.X-from.X+1, 0), Y: max(to.Y-from.Y+1, 0), Z: o.Size.Z}
+	result := magica.NewVoxelObject(size, o.PaletteData)
+
+	forEachVoxel(o, from, to, func(x, y, z int) {
+		result.Voxels[x-from.X][y-from.Y][z] = o.Voxels[x][y][z]
+	})
+
+	return result
+}
+
+// GetBounds returns the bounds of the whole object
+func GetBounds(o magica.VoxelObject) geometry.Bounds {
+	return geometry.Bounds{Max: geometry.Point{X: o.Size.X - 1, Y: o.Size.Y - 1, Z: o.Size.Z - 1}}
+}
@@ -0,0 +1,25 @@
+package transform
+
+import (
+	"github.com/mattkimber/gorender/internal/geometry"
+	"testing"
+)
+
+func TestCrop(t *testing.T) {
+	o := getTestObject()
+	box := geometry.Bounds{Min: geometry.Point{X: 2, Y: 1, Z: 1}, Max: geometry.Point{X: 7, Y: 3, Z: 1}}
+
+	result := Crop(o, box)
+
+	// Crops are clipped to the object and keep the full height
+	if result.Size.X != 3 || result.Size.Y != 3 || result.Size.Z != 5 {
+		t.Fatalf("expected size 3x3x5, got %v", result.Size)
+	}
+
+	expected := map[[3]int]byte{{0, 1, 2}: 2, {1, 1, 2}: 3}
+	result.Iterate(func(x, y, z int) {
+		if v, e := result.Voxels[x][y][z], expected[[3]int{x, y, z}]; v != e {
+			t.Errorf("voxel %d,%d,%d expected %d, got %d", x, y, z, e, v)
+		}
+	})
+}
@@ -0,0 +1,133 @@
+package transform
+
+import (
+	"fmt"
+	"github.com/mattkimber/gandalf/magica"
+	"github.com/mattkimber/gorender/internal/geometry"
+	"github.com/mattkimber/gorender/internal/manifest"
+	"math"
+)
+
+const (
+	AxisX = "x"
+	AxisY = "y"
+	AxisZ = "z"
+)
+
+// AnimateParts rotates each part of the frames by its angle at that frame
+func AnimateParts(frames []magica.VoxelObject, parts []manifest.Part) ([]magica.VoxelObject, error) {
+	result := make([]magica.VoxelObject, len(frames))
+	copy(result, frames)
+
+	for _, part := range parts {
+		box := part.GetBounds()
+
+		for f := range result {
+			rotated, err := RotatePart(result[f], box, part.GetPivot(), part.Axis, part.GetAngle(f, len(result)))
+			if err != nil {
+				return nil, fmt.Errorf("part %q: %v", part.Name, err)
+			}
+
+			result[f] = rotated
+		}
+	}
+
+	return result, nil
+}
+
+// RotatePart returns a copy of the object with the voxels inside the box
+// rotated by angle degrees about an axis through the pivot. Voxel i covers
+// the space from i to i+1, so the centre of the box is its natural pivot.
+// Each voxel in the output takes the colour of the voxel it was rotated from,
+// which avoids the holes that would appear if voxels were moved one by one.
+func RotatePart(o magica.VoxelObject, box geometry.Bounds, pivot geometry.Vector3, axis string, angle float64) (magica.VoxelObject, error) {
+	rotate, err := getRotation(axis, geometry.DegToRad(angle))
+	if err != nil {
+		return o, err
+	}
+
+	result := o.Copy()
+	if angle == 0 {
+		return result, nil
+	}
+
+	// The part is removed from its original position
+	forEachVoxel(o, box.Min, box.Max, func(x, y, z int) {
+		result.Voxels[x][y][z] = 0
+	})
+
+	// Only voxels within the rotated bounds of the box can be part of it
+	unrotate, _ := getRotation(axis, -geometry.DegToRad(angle))
+	from, to := getRotatedBounds(box, pivot, rotate)
+
+	forEachVoxel(o, from, to, func(x, y, z int) {
+		p := unrotate(geometry.Vector3{X: float64(x) + 0.5, Y: float64(y) + 0.5, Z: float64(z) + 0.5}.Subtract(pivot)).Add(pivot)
+		sx, sy, sz := int(math.Floor(p.X)), int(math.Floor(p.Y)), int(math.Floor(p.Z))
+
+		if !box.Contains(sx, sy, sz) || !isInside(o, sx, sy, sz) {
+			return
+		}
+
+		if v := o.Voxels[sx][sy][sz]; v != 0 {
+			result.Voxels[x][y][z] = v
+		}
+	})
+
+	return result, nil
+}
+
+func getRotation(axis string, theta float64) (func(geometry.Vector3) geometry.Vector3, error) {
+	sin, cos := math.Sin(theta), math.Cos(theta)
+
+	switch axis {
+	case AxisX:
+		return func(v geometry.Vector3) geometry.Vector3 {
+			return geometry.Vector3{X: v.X, Y: v.Y*cos - v.Z*sin, Z: v.Y*sin + v.Z*cos}
+		}, nil
+	case AxisY:
+		return func(v geometry.Vector3) geometry.Vector3 {
+			return geometry.Vector3{X: v.Z*sin + v.X*cos, Y: v.Y, Z: v.Z*cos - v.X*sin}
+		}, nil
+	case AxisZ:
+		return func(v geometry.Vector3) geometry.Vector3 {
+			return geometry.Vector3{X: v.X*cos - v.Y*sin, Y: v.X*sin + v.Y*cos, Z: v.Z}
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unknown rotation axis %q", axis)
+}
+
+// Get the voxels which the corners of the box can be rotated to
+func getRotatedBounds(box geometry.Bounds, pivot geometry.Vector3, rotate func(geometry.Vector3) geometry.Vector3) (from, to geometry.Point) {
+	lo := geometry.Vector3{X: math.MaxFloat64, Y: math.MaxFloat64, Z: math.MaxFloat64}
+	hi := geometry.Vector3{X: -math.MaxFloat64, Y: -math.MaxFloat64, Z: -math.MaxFloat64}
+
+	for _, x := range []int{box.Min.X, box.Max.X + 1} {
+		for _, y := range []int{box.Min.Y, box.Max.Y + 1} {
+			for _, z := range []int{box.Min.Z, box.Max.Z + 1} {
+				p := rotate(geometry.Vector3{X: float64(x), Y: float64(y), Z: float64(z)}.Subtract(pivot)).Add(pivot)
+				lo = geometry.Vector3{X: math.Min(lo.X, p.X), Y: math.Min(lo.Y, p.Y), Z: math.Min(lo.Z, p.Z)}
+				hi = geometry.Vector3{X: math.Max(hi.X, p.X), Y: math.Max(hi.Y, p.Y), Z: math.Max(hi.Z, p.Z)}
+			}
+		}
+	}
+
+	from = geometry.Point{X: int(math.Floor(lo.X)), Y: int(math.Floor(lo.Y)), Z: int(math.Floor(lo.Z))}
+	to = geometry.Point{X: int(math.Ceil(hi.X)), Y: int(math.Ceil(hi.Y)), Z: int(math.Ceil(hi.Z))}
+	return
+}
+
+// Call fn for every voxel of the object between min and max inclusive
+func forEachVoxel(o magica.VoxelObject, from, to geometry.Point, fn func(x, y, z int)) {
+	for x := max(from.X, 0); x <= to.X && x < o.Size.X; x++ {
+		for y := max(from.Y, 0); y <= to.Y && y < o.Size.Y; y++ {
+			for z := max(from.Z, 0); z <= to.Z && z < o.Size.Z; z++ {
+				fn(x, y, z)
+			}
+		}
+	}
+}
+
+func isInside(o magica.VoxelObject, x, y, z int) bool {
+	return x >= 0 && y >= 0 && z >= 0 && x < o.Size.X && y < o.Size.Y && z < o.Size.Z
+}
@@ -0,0 +1,83 @@
+package transform
+
+import (
+	gandalfgeo "github.com/mattkimber/gandalf/geometry"
+	"github.com/mattkimber/gandalf/magica"
+	"github.com/mattkimber/gorender/internal/geometry"
+	"github.com/mattkimber/gorender/internal/manifest"
+	"testing"
+)
+
+func getTestObject() magica.VoxelObject {
+	// A bar of 3 voxels along x, in the middle of a 5x5x5 object, with one
+	// voxel outside the part
+	o := magica.NewVoxelObject(gandalfgeo.Point{X: 5, Y: 5, Z: 5}, nil)
+	o.Voxels[1][2][2], o.Voxels[2][2][2], o.Voxels[3][2][2] = 1, 2, 3
+	o.Voxels[0][0][0] = 4
+	return o
+}
+
+func TestRotatePart(t *testing.T) {
+	o := getTestObject()
+	box := geometry.Bounds{Min: geometry.Point{X: 1, Y: 1, Z: 1}, Max: geometry.Point{X: 3, Y: 3, Z: 3}}
+	pivot := geometry.Vector3{X: 2.5, Y: 2.5, Z: 2.5}
+
+	testCases := []struct {
+		axis     string
+		angle    float64
+		expected map[[3]int]byte
+	}{
+		{AxisZ, 0, map[[3]int]byte{{1, 2, 2}: 1, {2, 2, 2}: 2, {3, 2, 2}: 3}},
+		{AxisZ, 90, map[[3]int]byte{{2, 1, 2}: 1, {2, 2, 2}: 2, {2, 3, 2}: 3}},
+		{AxisZ, 180, map[[3]int]byte{{3, 2, 2}: 1, {2, 2, 2}: 2, {1, 2, 2}: 3}},
+		{AxisY, 90, map[[3]int]byte{{2, 2, 3}: 1, {2, 2, 2}: 2, {2, 2, 1}: 3}},
+		{AxisX, 90, map[[3]int]byte{{1, 2, 2}: 1, {2, 2, 2}: 2, {3, 2, 2}: 3}},
+	}
+
+	for _, testCase := range testCases {
+		result, err := RotatePart(o, box, pivot, testCase.axis, testCase.angle)
+		if err != nil {
+			t.Fatalf("error rotating part: %v", err)
+		}
+
+		// Voxels outside the part are untouched
+		testCase.expected[[3]int{}] = 4
+
+		result.Iterate(func(x, y, z int) {
+			if v, expected := result.Voxels[x][y][z], testCase.expected[[3]int{x, y, z}]; v != expected {
+				t.Errorf("%s %v: voxel %d,%d,%d expected %d, got %d", testCase.axis, testCase.angle, x, y, z, expected, v)
+			}
+		})
+	}
+
+	if o.Voxels[1][2][2] != 1 || o.Voxels[2][1][2] != 0 {
+		t.Errorf("rotating a part changed the original object")
+	}
+
+	if _, err := RotatePart(o, box, pivot, "w", 90); err == nil {
+		t.Errorf("expected error for unknown axis")
+	}
+}
+
+func TestAnimateParts(t *testing.T) {
+	o := getTestObject()
+	parts := []manifest.Part{{
+		Name: "bar",
+		Min:  geometry.Point{X: 1, Y: 1, Z: 1},
+		Max:  geometry.Point{X: 3, Y: 3, Z: 3},
+		Axis: AxisZ,
+	}}
+
+	frames, err := AnimateParts([]magica.VoxelObject{o, o, o, o}, parts)
+	if err != nil {
+		t.Fatalf("error animating parts: %v", err)
+	}
+
+	// A full turn over 4 frames is 90 degrees per frame
+	expected := []gandalfgeo.Point{{X: 3, Y: 2, Z: 2}, {X: 2, Y: 3, Z: 2}, {X: 1, Y: 2, Z: 2}, {X: 2, Y: 1, Z: 2}}
+	for i, p := range expected {
+		if v := frames[i].Get(p); v != 3 {
+			t.Errorf("frame %d expected end of bar at %v, got colour %d", i, p, v)
+		}
+	}
+}
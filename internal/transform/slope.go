@@ -0,0 +1,142 @@
+package transform
+
+import (
+	gandalfgeo "github.com/mattkimber/gandalf/geometry"
+	"github.com/mattkimber/gandalf/magica"
+	"math"
+)
+
+// Corners of an OpenTTD slope, which are raised when their bit is set
+const (
+	SlopeW     = 1
+	SlopeS     = 2
+	SlopeE     = 4
+	SlopeN     = 8
+	SlopeSteep = 16
+)
+
+// Slopes are the 19 slopes a tile can have in OpenTTD, in the order the game
+// expects sprites for them (_slope_to_sprite_offset): flat, the 14 normal
+// slopes, then the steep slopes with the north, south, west and east corners
+// highest
+var Slopes = []int{
+	0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14,
+	SlopeSteep | SlopeE | SlopeN | SlopeW,
+	SlopeSteep | SlopeW | SlopeS | SlopeE,
+	SlopeSteep | SlopeN | SlopeW | SlopeS,
+	SlopeSteep | SlopeS | SlopeE | SlopeN,
+}
+
+type cornerHeights struct {
+	n, e, s, w float64
+}
+
+// GetSlopes returns a copy of the object for each slope in Slopes
+func GetSlopes(o magica.VoxelObject, height int) []magica.VoxelObject {
+	result := make([]magica.VoxelObject, len(Slopes))
+	for i, slope := range Slopes {
+		result[i] = ApplySlope(o, slope, height)
+	}
+
+	return result
+}
+
+// ApplySlope shears the columns of voxels in the object upwards to follow an
+// OpenTTD slope, where each step is height voxels. The object is made taller
+// by the height of a steep slope so every slope is the same size. The north
+// corner is the one at the highest x and y, the west corner is at low x and
+// the east corner at low y.
+func ApplySlope(o magica.VoxelObject, slope int, height int) magica.VoxelObject {
+	size := gandalfgeo.Point{X: o.Size.X, Y: o.Size.Y, Z: o.Size.Z + height*2}
+	result := magica.NewVoxelObject(size, o.PaletteData)
+	corners := getCornerHeights(slope)
+
+	shift := make([][]int, o.Size.X)
+	for x := range shift {
+		shift[x] = make([]int, o.Size.Y)
+		for y := range shift[x] {
+			u := 1 - (float64(x)+0.5)/float64(o.Size.X)
+			v := 1 - (float64(y)+0.5)/float64(o.Size.Y)
+			shift[x][y] = int(math.Round(corners.getHeight(u, v) * float64(height)))
+		}
+	}
+
+	for x := 0; x < o.Size.X; x++ {
+		for y := 0; y < o.Size.Y; y++ {
+			bottom := -1
+			for z := 0; z < o.Size.Z; z++ {
+				if v := o.Voxels[x][y][z]; v != 0 {
+					result.Voxels[x][y][z+shift[x][y]] = v
+					if bottom == -1 {
+						bottom = z
+					}
+				}
+			}
+
+			if bottom == -1 {
+				continue
+			}
+
+			// Extend the column down to the lowest of its neighbours, so there are
+			// no gaps where the slope goes up in steps
+			lowest := shift[x][y]
+			for _, n := range [][2]int{{x - 1, y}, {x + 1, y}, {x, y - 1}, {x, y + 1}} {
+				if n[0] >= 0 && n[1] >= 0 && n[0] < o.Size.X && n[1] < o.Size.Y {
+					lowest = min(lowest, shift[n[0]][n[1]])
+				}
+			}
+
+			for z := bottom + lowest; z < bottom+shift[x][y]; z++ {
+				result.Voxels[x][y][z] = o.Voxels[x][y][bottom]
+			}
+		}
+	}
+
+	return result
+}
+
+// Get the height of each corner in steps. The corner opposite the lowered
+// corner of a steep slope is raised by two steps.
+func getCornerHeights(slope int) (c cornerHeights) {
+	raised := func(corner int) float64 {
+		if slope&corner != 0 {
+			return 1
+		}
+		return 0
+	}
+
+	c = cornerHeights{n: raised(SlopeN), e: raised(SlopeE), s: raised(SlopeS), w: raised(SlopeW)}
+
+	if slope&SlopeSteep != 0 {
+		switch {
+		case c.n == 0:
+			c.s = 2
+		case c.s == 0:
+			c.n = 2
+		case c.e == 0:
+			c.w = 2
+		case c.w == 0:
+			c.e = 2
+		}
+	}
+
+	return
+}
+
+// Get the height of the slope at a point on the tile, where u runs from the
+// north corner to the west corner and v from the north corner to the east
+// corner. Like OpenTTD, tiles are made of two flat triangles either side of
+// a diagonal between two corners of the same height.
+func (c cornerHeights) getHeight(u, v float64) float64 {
+	if c.w == c.e {
+		if u+v <= 1 {
+			return c.n + (c.w-c.n)*u + (c.e-c.n)*v
+		}
+		return c.s + (c.w-c.s)*(1-v) + (c.e-c.s)*(1-u)
+	}
+
+	if u >= v {
+		return c.n + (c.w-c.n)*(u-v) + (c.s-c.n)*v
+	}
+	return c.n + (c.e-c.n)*(v-u) + (c.s-c.n)*u
+}
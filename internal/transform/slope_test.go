@@ -0,0 +1,125 @@
+package transform
+
+import (
+	gandalfgeo "github.com/mattkimber/gandalf/geometry"
+	"github.com/mattkimber/gandalf/magica"
+	"testing"
+)
+
+func getTestPlate() magica.VoxelObject {
+	o := magica.NewVoxelObject(gandalfgeo.Point{X: 8, Y: 8, Z: 2}, nil)
+	for x := 0; x < 8; x++ {
+		for y := 0; y < 8; y++ {
+			o.Voxels[x][y][0] = 1
+		}
+	}
+	return o
+}
+
+func getTop(o magica.VoxelObject, x, y int) int {
+	for z := o.Size.Z - 1; z >= 0; z-- {
+		if o.Voxels[x][y][z] != 0 {
+			return z
+		}
+	}
+	return -1
+}
+
+func TestGetCornerHeights(t *testing.T) {
+	testCases := []struct {
+		slope    int
+		expected cornerHeights
+	}{
+		{0, cornerHeights{}},
+		{SlopeN, cornerHeights{n: 1}},
+		{SlopeW | SlopeE, cornerHeights{w: 1, e: 1}},
+		{SlopeSteep | SlopeW | SlopeS | SlopeE, cornerHeights{w: 1, s: 2, e: 1}},
+		{SlopeSteep | SlopeE | SlopeN | SlopeW, cornerHeights{e: 1, n: 2, w: 1}},
+	}
+
+	for _, testCase := range testCases {
+		if result := getCornerHeights(testCase.slope); result != testCase.expected {
+			t.Errorf("slope %d: expected %v, got %v", testCase.slope, testCase.expected, result)
+		}
+	}
+}
+
+func TestApplySlope(t *testing.T) {
+	o := getTestPlate()
+
+	testCases := []struct {
+		slope      int
+		n, e, s, w int
+	}{
+		{0, 0, 0, 0, 0},
+		{SlopeN, 4, 0, 0, 0},
+		{SlopeN | SlopeS, 4, 0, 4, 0},
+		{SlopeN | SlopeE, 4, 4, 0, 0},
+		{SlopeSteep | SlopeE | SlopeN | SlopeW, 8, 4, 0, 4},
+	}
+
+	for _, testCase := range testCases {
+		result := ApplySlope(o, testCase.slope, 4)
+		if result.Size.Z != 10 {
+			t.Errorf("slope %d: expected height 10, got %d", testCase.slope, result.Size.Z)
+		}
+
+		// Corner voxels are half a voxel in from the corner of the tile
+		corners := []struct {
+			name     string
+			x, y     int
+			expected int
+		}{
+			{"north", 7, 7, testCase.n},
+			{"east", 7, 0, testCase.e},
+			{"south", 0, 0, testCase.s},
+			{"west", 0, 7, testCase.w},
+		}
+
+		for _, c := range corners {
+			if top := getTop(result, c.x, c.y); top < c.expected-1 || top > c.expected+1 {
+				t.Errorf("slope %d: expected %s corner at height %d, got %d", testCase.slope, c.name, c.expected, top)
+			}
+		}
+	}
+}
+
+func TestApplySlope_NoGaps(t *testing.T) {
+	result := ApplySlope(getTestPlate(), SlopeSteep|SlopeE|SlopeN|SlopeW, 8)
+
+	for x := 0; x < 8; x++ {
+		for y := 0; y < 8; y++ {
+			top := getTop(result, x, y)
+			for _, n := range [][2]int{{x + 1, y}, {x, y + 1}} {
+				if n[0] < 8 && n[1] < 8 && getTop(result, n[0], n[1])-top > 1 {
+					// The higher column must reach down to this one
+					if result.Voxels[n[0]][n[1]][top+1] == 0 {
+						t.Errorf("gap between %d,%d and %v", x, y, n)
+					}
+				}
+			}
+		}
+	}
+
+	if len(GetSlopes(getTestPlate(), 4)) != 19 {
+		t.Errorf("expected 19 slopes")
+	}
+}
+
+func TestSlopes(t *testing.T) {
+	// OpenTTD's sprite offset for each slope, from _slope_to_sprite_offset
+	expected := map[int]int{
+		0: 0, 1: 1, 2: 2, 3: 3, 4: 4, 5: 5, 6: 6, 7: 7, 8: 8, 9: 9, 10: 10, 11: 11, 12: 12, 13: 13, 14: 14,
+		29: 15, 23: 16, 27: 17, 30: 18,
+	}
+
+	if len(Slopes) != len(expected) {
+		t.Fatalf("expected %d slopes, got %d", len(expected), len(Slopes))
+	}
+
+	for i, slope := range Slopes {
+		if offset, ok := expected[slope]; !ok || offset != i {
+			t.Errorf("slope %d is sprite %d, expected %d", slope, i, offset)
+		}
+	}
+}
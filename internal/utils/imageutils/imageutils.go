@@ -43,3 +43,32 @@ func IsImageEqualToSubImage(img image.Image, sub image.Image, bounds image.Recta
 	}
 	return true
 }
+
+// ScaleNearest returns the image scaled up by a whole number factor, with each
+// pixel becoming a square of pixels. Paletted images stay paletted.
+func ScaleNearest(img image.Image, factor int) image.Image {
+	if factor <= 1 {
+		return img
+	}
+
+	b := img.Bounds()
+	bounds := image.Rect(b.Min.X*factor, b.Min.Y*factor, b.Max.X*factor, b.Max.Y*factor)
+
+	if p, ok := img.(*image.Paletted); ok {
+		result := image.NewPaletted(bounds, p.Palette)
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+				result.SetColorIndex(x, y, p.ColorIndexAt(x/factor, y/factor))
+			}
+		}
+		return result
+	}
+
+	result := image.NewRGBA(bounds)
+	for x := bounds.Min.X; x < bounds.Max.X; x++ {
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			result.Set(x, y, img.At(x/factor, y/factor))
+		}
+	}
+	return result
+}
@@ -71,3 +71,35 @@ func TestClearToColourIndex(t *testing.T) {
 		}
 	}
 }
+
+func TestScaleNearest(t *testing.T) {
+	rect := image.Rectangle{Max: image.Point{X: 2, Y: 2}}
+	img := image.NewPaletted(rect, palette.Plan9)
+	img.SetColorIndex(1, 0, 5)
+
+	scaled, ok := ScaleNearest(img, 3).(*image.Paletted)
+	if !ok {
+		t.Fatalf("expected scaled image to be paletted")
+	}
+
+	if expected := (image.Rectangle{Max: image.Point{X: 6, Y: 6}}); scaled.Bounds() != expected {
+		t.Errorf("scaled bounds %v not equal to expected %v", scaled.Bounds(), expected)
+	}
+
+	for x := 0; x < 6; x++ {
+		for y := 0; y < 6; y++ {
+			expected := uint8(0)
+			if x >= 3 && y < 3 {
+				expected = 5
+			}
+
+			if scaled.ColorIndexAt(x, y) != expected {
+				t.Errorf("colour at %d %d is %d - expected %d", x, y, scaled.ColorIndexAt(x, y), expected)
+			}
+		}
+	}
+
+	if ScaleNearest(img, 1) != image.Image(img) {
+		t.Errorf("expected image with a scale of 1 to be unchanged")
+	}
+}
@@ -0,0 +1,112 @@
+package voxelobject
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/mattkimber/gandalf/geometry"
+	"github.com/mattkimber/gandalf/magica"
+	"github.com/mattkimber/gandalf/magica/types"
+	"io"
+	"os"
+)
+
+// GetModels returns each model stored in a MagicaVoxel file as a separate
+// object, in the order they are stored. Unlike magica.FromFile the models are
+// not combined into one scene, so they can be used as the frames of an
+// animation. All models are padded to the same size so they are framed
+// identically when rendered.
+func GetModels(handle io.Reader) (models []magica.VoxelObject, err error) {
+	var header [8]byte
+	if _, err = io.ReadFull(handle, header[:]); err != nil || string(header[:4]) != "VOX " {
+		return nil, fmt.Errorf("header not valid")
+	}
+
+	var sizes []types.Size
+	var palette types.Palette
+
+	for {
+		var chunk [12]byte
+		if _, err = io.ReadFull(handle, chunk[:]); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("error reading chunk header: %v", err)
+		}
+
+		// MAIN is the only chunk with children, which follow it directly
+		data := make([]byte, binary.LittleEndian.Uint32(chunk[4:8]))
+		if _, err = io.ReadFull(handle, data); err != nil {
+			return nil, fmt.Errorf("error reading %s chunk: %v", chunk[:4], err)
+		}
+
+		rd := types.GetReader(data)
+
+		switch string(chunk[:4]) {
+		case "SIZE":
+			sizes = append(sizes, rd.GetSize())
+		case "XYZI":
+			if len(sizes) <= len(models) || len(data) < 4 {
+				return nil, fmt.Errorf("voxel data for model %d has no size", len(models))
+			}
+
+			// The first 4 bytes are the number of voxels
+			rd = types.GetReader(data[4:])
+			models = append(models, getModel(geometry.Point(sizes[len(models)]), rd.GetPointData()))
+		case "RGBA":
+			palette = rd.GetPalette()
+		}
+	}
+
+	if len(models) == 0 {
+		return nil, fmt.Errorf("file does not contain any models")
+	}
+
+	for i := range models {
+		models[i].PaletteData = palette
+	}
+
+	return PadToSameSize(models), nil
+}
+
+// ModelsFromFile returns the models stored in a MagicaVoxel file
+func ModelsFromFile(filename string) (models []magica.VoxelObject, err error) {
+	handle, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	defer handle.Close()
+	return GetModels(handle)
+}
+
+func getModel(size geometry.Point, points types.PointData) magica.VoxelObject {
+	o := magica.NewVoxelObject(size, nil)
+	for _, p := range points {
+		o.SafeSet(p.Point, p.Colour)
+	}
+
+	return o
+}
+
+// PadToSameSize returns the objects with empty space added so they are all the
+// size of the largest object. Voxels keep the same position from the origin.
+func PadToSameSize(objects []magica.VoxelObject) []magica.VoxelObject {
+	size := geometry.Point{}
+	for _, o := range objects {
+		size.X, size.Y, size.Z = max(size.X, o.Size.X), max(size.Y, o.Size.Y), max(size.Z, o.Size.Z)
+	}
+
+	result := make([]magica.VoxelObject, len(objects))
+	for i, o := range objects {
+		if o.Size == size {
+			result[i] = o
+			continue
+		}
+
+		result[i] = magica.NewVoxelObject(size, o.PaletteData)
+		o.Iterate(func(x, y, z int) {
+			result[i].Voxels[x][y][z] = o.Voxels[x][y][z]
+		})
+	}
+
+	return result
+}
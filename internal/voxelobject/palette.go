@@ -0,0 +1,64 @@
+package voxelobject
+
+import (
+	"fmt"
+	"github.com/mattkimber/gandalf/magica"
+	"github.com/mattkimber/gorender/internal/colour"
+)
+
+const voxPaletteSize = 256
+
+// GetPaletteEntries returns the colours stored in a MagicaVoxel file, ordered so
+// that each entry matches the index of processed voxels. Voxel colours are
+// offset by 2 when processed, and MagicaVoxel palettes start from colour 1, so
+// entry i is colour i+1 in the file.
+func GetPaletteEntries(o magica.VoxelObject) (entries []colour.PaletteEntry, err error) {
+	if len(o.PaletteData) < voxPaletteSize*4 {
+		return nil, fmt.Errorf("voxel file does not contain a full palette")
+	}
+
+	entries = make([]colour.PaletteEntry, voxPaletteSize)
+
+	for i := range entries {
+		j := ((i + 1) % voxPaletteSize) * 4
+		entries[i] = colour.PaletteEntry{R: o.PaletteData[j], G: o.PaletteData[j+1], B: o.PaletteData[j+2]}
+	}
+
+	return
+}
+
+// SetPaletteEntries replaces the palette stored with the object, using the same
+// order as GetPaletteEntries
+func SetPaletteEntries(o *magica.VoxelObject, entries []colour.PaletteEntry) {
+	o.PaletteData = make([]byte, voxPaletteSize*4)
+
+	for i := 0; i < len(entries) && i < voxPaletteSize; i++ {
+		j := ((i + 1) % voxPaletteSize) * 4
+		o.PaletteData[j], o.PaletteData[j+1], o.PaletteData[j+2], o.PaletteData[j+3] = entries[i].R, entries[i].G, entries[i].B, 255
+	}
+}
+
+// GetColourCounts returns the number of voxels using each processed index
+func GetColourCounts(o magica.VoxelObject) (counts [voxPaletteSize]int) {
+	o.Iterate(func(x, y, z int) {
+		if v := o.Voxels[x][y][z]; v != 0 {
+			counts[v-2]++
+		}
+	})
+
+	return
+}
+
+// RemapColours returns a copy of the object with each voxel's colour replaced
+// according to the index map, which is indexed by processed index
+func RemapColours(o magica.VoxelObject, indexMap [voxPaletteSize]byte) (result magica.VoxelObject) {
+	result = o.Copy()
+
+	result.Iterate(func(x, y, z int) {
+		if v := result.Voxels[x][y][z]; v != 0 {
+			result.Voxels[x][y][z] = indexMap[v-2] + 2
+		}
+	})
+
+	return
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/mattkimber/gorender/internal/colour"
 	"github.com/mattkimber/gorender/internal/geometry"
 	"log"
+	"math"
 	"sync"
 )
 
@@ -12,7 +13,7 @@ type ProcessedElement struct {
 	Normal         geometry.Vector3
 	AveragedNormal geometry.Vector3
 	Detail         float64
-	Occlusion      int
+	Occlusion      float64
 	Index          byte
 	IsSurface      bool
 }
@@ -21,6 +22,11 @@ type ProcessedVoxelObject struct {
 	Elements [][][]ProcessedElement
 	Size     geometry.Point
 	Palette  *colour.Palette
+
+	// Working state used while calculating normals. This lives on the object
+	// rather than in the package so several objects can be processed at once.
+	borderedElementLookup [][][]int
+	startValues           map[int]radiusStartValues
 }
 
 type startValue struct {
@@ -32,26 +38,27 @@ type radiusStartValues struct {
 	K [][]startValue
 }
 
-var startValues map[int]radiusStartValues
-var startValuesLock sync.RWMutex
-
-var borderedElementLookup [][][]int
-
 const normalRadius = 3
 const normalAverageDistance = 1
 const occlusionRadius = 4
+const occlusionLimit = 10
+const defaultOcclusionRays = 32
+const defaultOcclusionDistance = 12.0
 const accessBorder = 8
 
 func GetProcessedVoxelObject(o magica.VoxelObject, pal *colour.Palette, isTiled bool, tilingMode string, hasBase bool) (p ProcessedVoxelObject) {
 	p.Size = geometry.FromGandalfPoint(o.Size)
 	p.Palette = pal
 
-	if startValues == nil {
-		startValues = map[int]radiusStartValues{}
-	}
-
 	p.setElements(o, isTiled, tilingMode, hasBase)
+	p.setRadiusStartValues()
 	p.calculatePass(processFirstPassElement)
+
+	// The lookups are only needed for normal calculation, so don't keep
+	// them around for the lifetime of the object
+	p.borderedElementLookup = nil
+	p.startValues = nil
+
 	p.calculatePass(processSecondPassElement)
 
 	return
@@ -89,7 +96,7 @@ func processSecondPassElement(p *ProcessedVoxelObject, x int, y int, z int) {
 	}
 
 	p.Elements[x][y][z].AveragedNormal = p.getAverageNormal(x, y, z)
-	p.Elements[x][y][z].Occlusion = p.getOcclusion(x, y, z)
+	p.Elements[x][y][z].Occlusion = float64(p.getOcclusion(x, y, z)) / occlusionLimit
 	p.Elements[x][y][z].Detail = p.getDetail(x, y, z)
 }
 
@@ -101,18 +108,22 @@ func (p *ProcessedVoxelObject) getNormalRadius(index byte) (radius int) {
 	return
 }
 
-// Pre-calculating the radius start values gives approx 20% speedup by avoiding
-// a branch prediction miss
-func getRadiusStartValues(radius int) (values radiusStartValues) {
+// Calculate the start values for every radius the palette can produce before
+// the normal pass runs, so the pass itself only ever reads from the map.
+func (p *ProcessedVoxelObject) setRadiusStartValues() {
+	p.startValues = map[int]radiusStartValues{}
 
-	startValuesLock.Lock()
-	if values, ok := startValues[radius]; ok {
-		startValuesLock.Unlock()
-		return values
-	} else {
-		startValuesLock.Unlock()
+	for i := 0; i < 256; i++ {
+		radius := p.getNormalRadius(byte(i))
+		if _, ok := p.startValues[radius]; !ok {
+			p.startValues[radius] = getRadiusStartValues(radius)
+		}
 	}
+}
 
+// Pre-calculating the radius start values gives approx 20% speedup by avoiding
+// a branch prediction miss
+func getRadiusStartValues(radius int) (values radiusStartValues) {
 	values.J, values.K = make([]startValue, radius*2+1), make([][]startValue, radius*2+1)
 
 	for i := -radius; i <= radius; i++ {
@@ -147,10 +158,6 @@ func getRadiusStartValues(radius int) (values radiusStartValues) {
 		values.J[i+radius] = startValue{min: jMin, max: jMax}
 	}
 
-	startValuesLock.Lock()
-	startValues[radius] = values
-	startValuesLock.Unlock()
-
 	return
 }
 
@@ -209,7 +216,7 @@ func (p *ProcessedVoxelObject) calculateNormal(x, y, z int) (normal geometry.Vec
 
 	radius := p.getNormalRadius(p.Elements[x][y][z].Index)
 
-	values := getRadiusStartValues(radius)
+	values := p.startValues[radius]
 
 	x += accessBorder
 	y += accessBorder
@@ -220,7 +227,7 @@ func (p *ProcessedVoxelObject) calculateNormal(x, y, z int) (normal geometry.Vec
 	for i := -radius; i <= radius; i++ {
 		for j := values.J[i+radius].min; j <= values.J[i+radius].max; j++ {
 			for k := values.K[i+radius][j+radius].min; k <= values.K[i+radius][j+radius].max; k++ {
-				v := borderedElementLookup[x+i][y+j][z+k]
+				v := p.borderedElementLookup[x+i][y+j][z+k]
 				ti -= i * v
 				tj -= j * v
 				tk -= k * v
@@ -327,7 +334,7 @@ func (p *ProcessedVoxelObject) getOcclusion(x, y, z int) (occlusion int) {
 				if vec.Length() < distanceF && vec.Dot(normal) < 0 {
 					if p.Elements[q+i][w+j][e+k].IsSurface {
 						occlusion++
-						if occlusion >= 10 {
+						if occlusion >= occlusionLimit {
 							return
 						}
 					}
@@ -339,17 +346,104 @@ func (p *ProcessedVoxelObject) getOcclusion(x, y, z int) (occlusion int) {
 	return
 }
 
+// Replace the neighbourhood-based occlusion with ray traced ambient occlusion,
+// which is the proportion of rays cast out over the hemisphere above each
+// surface voxel that hit other geometry within the given distance.
+func (p *ProcessedVoxelObject) CalculateAmbientOcclusion(rays int, distance float64) {
+	if rays <= 0 {
+		rays = defaultOcclusionRays
+	}
+
+	if distance <= 0 {
+		distance = defaultOcclusionDistance
+	}
+
+	directions := getHemisphereDirections(rays)
+
+	p.calculatePass(func(p *ProcessedVoxelObject, x int, y int, z int) {
+		p.Elements[x][y][z].Occlusion = p.getRayTracedOcclusion(x, y, z, directions, distance)
+	})
+}
+
+// Get a fixed set of cosine-weighted directions over the hemisphere around +Z,
+// spread evenly with a Fibonacci spiral so the result is the same every run.
+func getHemisphereDirections(count int) (directions []geometry.Vector3) {
+	directions = make([]geometry.Vector3, count)
+	goldenAngle := math.Pi * (3.0 - math.Sqrt(5.0))
+
+	for i := range directions {
+		r := math.Sqrt((float64(i) + 0.5) / float64(count))
+		phi := float64(i) * goldenAngle
+		directions[i] = geometry.Vector3{X: r * math.Cos(phi), Y: r * math.Sin(phi), Z: math.Sqrt(1 - (r * r))}
+	}
+
+	return
+}
+
+func (p *ProcessedVoxelObject) getRayTracedOcclusion(x, y, z int, directions []geometry.Vector3, distance float64) (occlusion float64) {
+	if !p.Elements[x][y][z].IsSurface {
+		return
+	}
+
+	// Normals point into the object, so flip to get the outward direction
+	outward := geometry.Zero().Subtract(p.Elements[x][y][z].AveragedNormal)
+	if outward.Length() < 0.01 {
+		return
+	}
+
+	// Start from the surface of the voxel rather than its centre, so that
+	// rays grazing a flat surface don't hit the voxels alongside
+	tangent, bitangent := outward.Tangents()
+	origin := geometry.Vector3{X: float64(x) + 0.5, Y: float64(y) + 0.5, Z: float64(z) + 0.5}.Add(outward.MultiplyByConstant(0.5))
+
+	hits := 0
+	for _, d := range directions {
+		ray := tangent.MultiplyByConstant(d.X).Add(bitangent.MultiplyByConstant(d.Y)).Add(outward.MultiplyByConstant(d.Z))
+		if p.isRayOccluded(origin, ray, x, y, z, distance) {
+			hits++
+		}
+	}
+
+	return float64(hits) / float64(len(directions))
+}
+
+func (p *ProcessedVoxelObject) isRayOccluded(origin, ray geometry.Vector3, x, y, z int, distance float64) bool {
+	const step = 0.5
+
+	for t := step; t <= distance; t += step {
+		loc := origin.Add(ray.MultiplyByConstant(t))
+		if loc.X < 0 || loc.Y < 0 || loc.Z < 0 {
+			return false
+		}
+
+		lx, ly, lz := int(loc.X), int(loc.Y), int(loc.Z)
+		if lx >= p.Size.X || ly >= p.Size.Y || lz >= p.Size.Z {
+			return false
+		}
+
+		if (lx != x || ly != y || lz != z) && !p.isInvisibleColourIndex(p.Elements[lx][ly][lz].Index) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (p *ProcessedVoxelObject) isSurface(x, y, z int) bool {
-	// A voxel is a surface voxel if any of the adjacent directions is zero
+	// A voxel is a surface voxel if any of the adjacent directions is zero (or can be seen through)
 	// The edges of the voxel object are trivially surface voxels
 	return !p.isInvisibleColourIndex(p.Elements[x][y][z].Index) && (x == 0 || y == 0 || z == 0 || // Edges are surface voxels
 		x == p.Size.X-1 || y == p.Size.Y-1 || z == p.Size.Z-1 || // Edges are surface voxels
-		p.isInvisibleColourIndex(p.Elements[x+1][y][z].Index) ||
-		p.isInvisibleColourIndex(p.Elements[x-1][y][z].Index) ||
-		p.isInvisibleColourIndex(p.Elements[x][y+1][z].Index) ||
-		p.isInvisibleColourIndex(p.Elements[x][y-1][z].Index) ||
-		p.isInvisibleColourIndex(p.Elements[x][y][z+1].Index) ||
-		p.isInvisibleColourIndex(p.Elements[x][y][z-1].Index))
+		p.isSeeThroughColourIndex(p.Elements[x+1][y][z].Index) ||
+		p.isSeeThroughColourIndex(p.Elements[x-1][y][z].Index) ||
+		p.isSeeThroughColourIndex(p.Elements[x][y+1][z].Index) ||
+		p.isSeeThroughColourIndex(p.Elements[x][y-1][z].Index) ||
+		p.isSeeThroughColourIndex(p.Elements[x][y][z+1].Index) ||
+		p.isSeeThroughColourIndex(p.Elements[x][y][z-1].Index))
+}
+
+func (p *ProcessedVoxelObject) isSeeThroughColourIndex(idx byte) bool {
+	return p.isInvisibleColourIndex(idx) || p.Palette.Entries[idx].Range.IsTransparent
 }
 
 func (p *ProcessedVoxelObject) isInvisibleColourIndex(idx byte) bool {
@@ -376,7 +470,7 @@ func reflect101(a, n int) int {
 
 func (p *ProcessedVoxelObject) setElements(r magica.VoxelObject, isTiled bool, tilingMode string, hasBase bool) {
 	p.Elements = make([][][]ProcessedElement, p.Size.X)
-	borderedElementLookup = make([][][]int, p.Size.X+(accessBorder*2))
+	p.borderedElementLookup = make([][][]int, p.Size.X+(accessBorder*2))
 
 	sx, sy, sz := p.Size.X, p.Size.Y, p.Size.Z
 
@@ -393,35 +487,35 @@ func (p *ProcessedVoxelObject) setElements(r magica.VoxelObject, isTiled bool, t
 	}
 
 	for x := 0; x < p.Size.X+(accessBorder*2); x++ {
-		borderedElementLookup[x] = make([][]int, p.Size.Y+(accessBorder*2))
+		p.borderedElementLookup[x] = make([][]int, p.Size.Y+(accessBorder*2))
 		for y := 0; y < p.Size.Y+(accessBorder*2); y++ {
-			borderedElementLookup[x][y] = make([]int, p.Size.Z+(accessBorder*2))
+			p.borderedElementLookup[x][y] = make([]int, p.Size.Z+(accessBorder*2))
 			for z := 0; z < p.Size.Z+(accessBorder*2); z++ {
 				if isTiled {
 					if tilingMode == "repeat" {
 						if r.Voxels[min(max(x-accessBorder, 0), p.Size.X-1)][min(max(y-accessBorder, 0), p.Size.Y-1)][min(max(z-accessBorder, 0), p.Size.Z-1)] == 0 {
-							borderedElementLookup[x][y][z] = 1
+							p.borderedElementLookup[x][y][z] = 1
 						}
 					} else if tilingMode == "reflect" {
 						if r.Voxels[reflect(x-accessBorder, p.Size.X)][reflect(y-accessBorder, p.Size.Y)][reflect(z-accessBorder, p.Size.Z)] == 0 {
-							borderedElementLookup[x][y][z] = 1
+							p.borderedElementLookup[x][y][z] = 1
 						}
 					} else if tilingMode == "reflect101" {
 						if r.Voxels[reflect101(x-accessBorder, p.Size.X)][reflect101(y-accessBorder, p.Size.Y)][reflect101(z-accessBorder, p.Size.Z)] == 0 {
-							borderedElementLookup[x][y][z] = 1
+							p.borderedElementLookup[x][y][z] = 1
 						}
 					} else {
 						if r.Voxels[(x+sx-accessBorder)%p.Size.X][(y+sy-accessBorder)%p.Size.Y][(z+sz-accessBorder)%p.Size.Z] == 0 {
-							borderedElementLookup[x][y][z] = 1
+							p.borderedElementLookup[x][y][z] = 1
 						}
 					}
 				} else {
-					borderedElementLookup[x][y][z] = 1
+					p.borderedElementLookup[x][y][z] = 1
 				}
 
 				if hasBase && z < accessBorder {
 					// If this object has a solid base then the lookup below z=0 is considered to be solid
-					borderedElementLookup[x][y][z] = 0
+					p.borderedElementLookup[x][y][z] = 0
 				}
 			}
 		}
@@ -440,7 +534,7 @@ func (p *ProcessedVoxelObject) setElements(r magica.VoxelObject, isTiled bool, t
 				// a value that can be multiplied by every time rather than needing an `if thing == 0`
 				// in the inner normal calculation loop
 				if r.Voxels[x][y][z] != 0 && !isTiled {
-					borderedElementLookup[x+accessBorder][y+accessBorder][z+accessBorder] = 0
+					p.borderedElementLookup[x+accessBorder][y+accessBorder][z+accessBorder] = 0
 				}
 			}
 		}
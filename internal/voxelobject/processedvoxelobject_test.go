@@ -1,9 +1,13 @@
 package voxelobject
 
 import (
+	"bytes"
+	"encoding/binary"
+	gandalfgeo "github.com/mattkimber/gandalf/geometry"
 	"github.com/mattkimber/gandalf/magica"
 	"github.com/mattkimber/gorender/internal/colour"
 	"github.com/mattkimber/gorender/internal/geometry"
+	"sync"
 	"testing"
 )
 
@@ -134,3 +138,231 @@ func TestProcessedVoxelObject_getOcclusion(t *testing.T) {
 	}
 
 }
+
+func TestGetProcessedVoxelObject_Concurrent(t *testing.T) {
+	// Run with -race to check that processing does not rely on shared state
+	files := []string{"testcube", "testcube_big", "occlude"}
+
+	entries := make([]colour.PaletteEntry, 256)
+	pal := colour.Palette{Entries: entries}
+	pal.SetRanges([]colour.PaletteRange{{Start: 0, End: 255}})
+
+	objects := make([]magica.VoxelObject, len(files))
+	expected := make([]ProcessedVoxelObject, len(files))
+
+	for i, f := range files {
+		mv, err := magica.FromFile("testdata/" + f)
+		if err != nil {
+			t.Fatalf("error loading test file: %v", err)
+		}
+		objects[i] = mv
+		expected[i] = GetProcessedVoxelObject(mv, &pal, false, "normal", false)
+	}
+
+	const repeats = 4
+	results := make([]ProcessedVoxelObject, len(files)*repeats)
+
+	wg := sync.WaitGroup{}
+	wg.Add(len(results))
+
+	for i := range results {
+		idx := i
+		go func() {
+			defer wg.Done()
+			results[idx] = GetProcessedVoxelObject(objects[idx%len(files)], &pal, false, "normal", false)
+		}()
+	}
+
+	wg.Wait()
+
+	for i, result := range results {
+		if !isObjectEqual(result, expected[i%len(files)]) {
+			t.Errorf("concurrent result %d for %s did not match sequential output", i, files[i%len(files)])
+		}
+	}
+}
+
+func isObjectEqual(a, b ProcessedVoxelObject) bool {
+	if a.Size != b.Size {
+		return false
+	}
+
+	for x := 0; x < a.Size.X; x++ {
+		for y := 0; y < a.Size.Y; y++ {
+			for z := 0; z < a.Size.Z; z++ {
+				if a.Elements[x][y][z] != b.Elements[x][y][z] {
+					return false
+				}
+			}
+		}
+	}
+
+	return true
+}
+
+func TestProcessedVoxelObject_CalculateAmbientOcclusion(t *testing.T) {
+	// A floor with a wall along one edge
+	mv := magica.NewVoxelObject(gandalfgeo.Point{X: 12, Y: 12, Z: 8}, nil)
+	for x := 0; x < 12; x++ {
+		for y := 0; y < 12; y++ {
+			for z := 0; z < 8; z++ {
+				if z < 3 || x < 2 {
+					mv.Voxels[x][y][z] = 3
+				}
+			}
+		}
+	}
+
+	entries := make([]colour.PaletteEntry, 256)
+	pal := colour.Palette{Entries: entries}
+	pal.SetRanges([]colour.PaletteRange{{Start: 0, End: 255}})
+
+	p := GetProcessedVoxelObject(mv, &pal, false, "normal", false)
+	p.CalculateAmbientOcclusion(64, 8)
+
+	open, corner := p.Elements[9][6][2].Occlusion, p.Elements[2][6][2].Occlusion
+
+	if open != 0 {
+		t.Errorf("Occlusion on open floor is %f, expected 0", open)
+	}
+
+	if corner <= open || corner > 1 {
+		t.Errorf("Occlusion in corner is %f, expected greater than open floor and at most 1", corner)
+	}
+
+	if p.Elements[6][6][1].Occlusion != 0 {
+		t.Errorf("Occlusion at non-surface voxel is %f, expected 0", p.Elements[6][6][1].Occlusion)
+	}
+}
+
+func Test_getHemisphereDirections(t *testing.T) {
+	directions := getHemisphereDirections(16)
+
+	if len(directions) != 16 {
+		t.Fatalf("Got %d directions, expected 16", len(directions))
+	}
+
+	for i, d := range directions {
+		if d.Z <= 0 {
+			t.Errorf("Direction %d (%v) is not in the upper hemisphere", i, d)
+		}
+
+		if length := d.Length(); length < 0.9999 || length > 1.0001 {
+			t.Errorf("Direction %d (%v) has length %f, expected 1", i, d, length)
+		}
+	}
+}
+
+func TestGetPaletteEntries(t *testing.T) {
+	data := make([]byte, 1024)
+	for i := 0; i < 256; i++ {
+		data[i*4], data[i*4+1], data[i*4+2], data[i*4+3] = byte(i), 0, 255-byte(i), 255
+	}
+
+	// Raw voxel colour 3 is processed as index 1
+	mv := magica.NewVoxelObject(gandalfgeo.Point{X: 1, Y: 1, Z: 1}, data)
+	mv.Voxels[0][0][0] = 3
+
+	entries, err := GetPaletteEntries(mv)
+	if err != nil {
+		t.Fatalf("error getting palette: %v", err)
+	}
+
+	pal := colour.Palette{Entries: entries}
+	pal.SetRanges([]colour.PaletteRange{{Start: 1, End: 255}})
+
+	p := GetProcessedVoxelObject(mv, &pal, false, "normal", false)
+	index := p.Elements[0][0][0].Index
+
+	if expected := (colour.PaletteEntry{R: 2, G: 0, B: 253}); entries[index].R != expected.R || entries[index].G != expected.G || entries[index].B != expected.B {
+		t.Errorf("Voxel colour expected %v, got %v", expected, entries[index])
+	}
+
+	if entries[255].R != 0 || entries[255].B != 255 {
+		t.Errorf("Entry 255 expected to be the first colour in the file, got %v", entries[255])
+	}
+
+	if _, err := GetPaletteEntries(magica.NewVoxelObject(gandalfgeo.Point{X: 1, Y: 1, Z: 1}, nil)); err == nil {
+		t.Errorf("Expected error for voxel object with no palette")
+	}
+}
+
+func TestRemapColours(t *testing.T) {
+	mv := magica.NewVoxelObject(gandalfgeo.Point{X: 2, Y: 1, Z: 1}, make([]byte, 1024))
+	mv.Voxels[0][0][0] = 3
+
+	var indexMap [256]byte
+	indexMap[1] = 10
+
+	result := RemapColours(mv, indexMap)
+
+	if result.Voxels[0][0][0] != 12 || result.Voxels[1][0][0] != 0 {
+		t.Errorf("Expected voxels to be remapped to [12 0], got [%d %d]", result.Voxels[0][0][0], result.Voxels[1][0][0])
+	}
+
+	if mv.Voxels[0][0][0] != 3 {
+		t.Errorf("Remapping changed the original object")
+	}
+
+	if counts := GetColourCounts(result); counts[10] != 1 || counts[1] != 0 {
+		t.Errorf("Expected one voxel of colour 10, got counts %d and %d", counts[10], counts[1])
+	}
+
+	entries := make([]colour.PaletteEntry, 256)
+	entries[10] = colour.PaletteEntry{R: 1, G: 2, B: 3}
+	SetPaletteEntries(&result, entries)
+
+	if e, err := GetPaletteEntries(result); err != nil || e[10].R != 1 || e[10].G != 2 || e[10].B != 3 {
+		t.Errorf("Expected palette entry 10 to be set, got %v (%v)", e[10], err)
+	}
+}
+
+func TestGetModels(t *testing.T) {
+	// Two models of different sizes, each with one voxel
+	var chunks bytes.Buffer
+	writeChunk(&chunks, "SIZE", 1, 1, 1)
+	writeChunk(&chunks, "XYZI", 1, 0x03000000)
+	writeChunk(&chunks, "SIZE", 2, 3, 4)
+	writeChunk(&chunks, "XYZI", 1, 0x05030201)
+	writeChunk(&chunks, "RGBA", make([]uint32, 256)...)
+
+	var file bytes.Buffer
+	file.WriteString("VOX ")
+	_ = binary.Write(&file, binary.LittleEndian, int32(150))
+	file.WriteString("MAIN")
+	_ = binary.Write(&file, binary.LittleEndian, []int32{0, int32(chunks.Len())})
+	file.Write(chunks.Bytes())
+
+	models, err := GetModels(&file)
+	if err != nil {
+		t.Fatalf("error reading models: %v", err)
+	}
+
+	if len(models) != 2 {
+		t.Fatalf("expected 2 models, got %d", len(models))
+	}
+
+	for i, m := range models {
+		if m.Size != (gandalfgeo.Point{X: 2, Y: 3, Z: 4}) {
+			t.Errorf("model %d expected to be padded to size 2x3x4, got %v", i, m.Size)
+		}
+
+		if len(m.PaletteData) != 1024 {
+			t.Errorf("model %d has no palette", i)
+		}
+	}
+
+	if models[0].Voxels[0][0][0] != 3 || models[1].Voxels[1][2][3] != 5 || models[1].Voxels[0][0][0] != 0 {
+		t.Errorf("model voxels not loaded correctly")
+	}
+
+	if _, err := GetModels(bytes.NewReader([]byte("not a voxel file"))); err == nil {
+		t.Errorf("expected error for invalid file")
+	}
+}
+
+func writeChunk(buf *bytes.Buffer, id string, data ...uint32) {
+	buf.WriteString(id)
+	_ = binary.Write(buf, binary.LittleEndian, []int32{int32(len(data) * 4), 0})
+	_ = binary.Write(buf, binary.LittleEndian, data)
+}